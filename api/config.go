@@ -1,18 +1,41 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 
 	_ "github.com/lib/pq"
+	"github.com/par1ram/song-library/common"
 	"github.com/par1ram/song-library/internal/database"
 	"github.com/sirupsen/logrus"
 )
 
 type ApiConfig struct {
-	DB         *database.Queries
-	HTTPClient *http.Client
-	Logger     *logrus.Logger
+	DB       *database.Queries
+	Conn     *sql.DB
+	SongInfo SongInfoProvider
+	Logger   *logrus.Logger
+
+	// SongInfoBreaker guards the external song info API; its state is
+	// reported by SongInfoStatus.
+	SongInfoBreaker *CircuitBreaker
+	// SongInfoCache is nil when caching is disabled.
+	SongInfoCache *CachingSongInfoProvider
+
+	// AutoCreateGroups makes InsertSong create unknown groups instead of
+	// answering 404. A request can still override it with create_group.
+	AutoCreateGroups bool
+	// AsyncEnrichment makes InsertSong store songs as pending and leave the
+	// external API call to the EnrichmentWorker. A request can override it
+	// with async.
+	AsyncEnrichment bool
+	// SearchConfig is the default PostgreSQL text search configuration
+	// used by SearchSongs.
+	SearchConfig string
+	// RequireIfMatch makes song writes by id fail with 428 unless they
+	// carry If-Match.
+	RequireIfMatch bool
 }
 
 func NewApiConfig(con *sql.DB, logLevel logrus.Level) *ApiConfig {
@@ -20,9 +43,61 @@ func NewApiConfig(con *sql.DB, logLevel logrus.Level) *ApiConfig {
 	logger.SetLevel(logLevel)
 	logger.SetFormatter(&logrus.JSONFormatter{})
 
+	breaker := NewCircuitBreaker(common.GetExternalApiBreakerThreshold(), common.GetExternalApiBreakerCooldown())
+	var songInfo SongInfoProvider = &ResilientSongInfoProvider{
+		Provider:     NewHTTPSongInfoProvider(common.GetExternalApiURL(), &http.Client{}),
+		Timeout:      common.GetExternalApiTimeout(),
+		TotalTimeout: common.GetExternalApiTotalTimeout(),
+		Retry: RetryPolicy{
+			Retries:   common.GetExternalApiRetries(),
+			BaseDelay: common.GetExternalApiRetryBaseDelay(),
+			MaxDelay:  common.GetExternalApiRetryMaxDelay(),
+		},
+		Breaker: breaker,
+	}
+
+	queries := database.New(con)
+	backend := common.GetSongInfoCacheBackend()
+
+	var cache *CachingSongInfoProvider
+	switch backend {
+	case "memory":
+		cache = &CachingSongInfoProvider{Cache: NewLRUSongInfoCache(common.GetSongInfoCacheSize())}
+	case "postgres":
+		cache = &CachingSongInfoProvider{Cache: &PostgresSongInfoCache{DB: queries}}
+		if purged, err := queries.DeleteExpiredSongInfoCacheEntries(context.Background()); err != nil {
+			logger.WithError(err).Warn("Failed to purge expired song info cache entries")
+		} else {
+			logger.WithField("purged", purged).Debug("Purged expired song info cache entries")
+		}
+	}
+	if cache != nil {
+		cache.Provider = songInfo
+		cache.Backend = backend
+		cache.TTL = common.GetSongInfoCacheTTL()
+		cache.NegativeTTL = common.GetSongInfoCacheNegativeTTL()
+		songInfo = cache
+	}
+
+	if fixturesPath := common.GetSongInfoFixturesPath(); fixturesPath != "" {
+		fixtures, err := NewStaticSongInfoProviderFromFile(fixturesPath)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to load song info fixtures")
+		}
+		songInfo = ChainSongInfoProvider{fixtures, songInfo}
+	}
+
 	return &ApiConfig{
-		DB:         database.New(con),
-		HTTPClient: &http.Client{},
-		Logger:     logger,
+		DB:       queries,
+		Conn:     con,
+		SongInfo: songInfo,
+		Logger:   logger,
+
+		SongInfoBreaker:  breaker,
+		SongInfoCache:    cache,
+		AutoCreateGroups: common.GetAutoCreateGroups(),
+		AsyncEnrichment:  common.GetAsyncEnrichment(),
+		SearchConfig:     common.GetSearchConfig(),
+		RequireIfMatch:   common.GetRequireIfMatch(),
 	}
 }
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/par1ram/song-library/internal/apierr"
+)
+
+const (
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+)
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqForeignKeyViolation
+}
+
+// errGroupNotFound reports field as pointing at a group that does not exist.
+func errGroupNotFound(field string) *apierr.Error {
+	return apierr.NewUnprocessable("The referenced group does not exist",
+		apierr.FieldError{Field: field, Message: "Group does not exist"})
+}
+
+// songWriteError maps a failed song write to the error for the client.
+// Constraint violations the checks before the write could not prevent,
+// e.g. a group deleted concurrently, are reported instead of a 500.
+func songWriteError(detail string, err error) *apierr.Error {
+	switch {
+	case isForeignKeyViolation(err):
+		e := errGroupNotFound("group_id")
+		e.Err = err
+		return e
+	case isUniqueViolation(err):
+		return apierr.Wrap(apierr.Conflict, "The song conflicts with an existing song", err)
+	}
+	return apierr.NewInternal(detail, err)
+}
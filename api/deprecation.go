@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Deprecated marks a legacy route with the Deprecation header
+// and points clients to its replacement with a successor-version link.
+func (cfg *ApiConfig) Deprecated(successor string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg.Logger.WithFields(logrus.Fields{
+				"method":    r.Method,
+				"path":      r.URL.Path,
+				"successor": successor,
+			}).Warn("Deprecated route called")
+
+			w.Header().Set("Deprecation", "true")
+			w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
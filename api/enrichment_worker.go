@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	EnrichmentPending = "pending"
+	EnrichmentDone    = "done"
+	EnrichmentFailed  = "failed"
+)
+
+// EnrichmentWorker fills in details of songs inserted in async mode. Jobs
+// live in the enrichment_jobs table and are leased with FOR UPDATE SKIP
+// LOCKED, so any number of workers in any number of instances can share
+// the queue. A lease that is not completed in time (e.g. the instance
+// died) expires and the job is picked up again.
+type EnrichmentWorker struct {
+	cfg *ApiConfig
+
+	Workers       int
+	PollInterval  time.Duration
+	LeaseDuration time.Duration
+	MaxAttempts   int
+	Retry         RetryPolicy
+}
+
+func (cfg *ApiConfig) NewEnrichmentWorker(workers int, pollInterval, leaseDuration time.Duration, maxAttempts int, retry RetryPolicy) *EnrichmentWorker {
+	return &EnrichmentWorker{
+		cfg:           cfg,
+		Workers:       workers,
+		PollInterval:  pollInterval,
+		LeaseDuration: leaseDuration,
+		MaxAttempts:   maxAttempts,
+		Retry:         retry,
+	}
+}
+
+// Run blocks until ctx is cancelled and all workers have stopped.
+func (ew *EnrichmentWorker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for n := 0; n < ew.Workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ew.loop(ctx, n)
+		}()
+	}
+	wg.Wait()
+}
+
+func (ew *EnrichmentWorker) loop(ctx context.Context, worker int) {
+	logger := ew.cfg.Logger.WithField("worker", worker)
+	logger.Info("Enrichment worker started")
+
+	for {
+		processed, err := ew.processNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.WithError(err).Error("Failed to process enrichment job")
+		}
+		if processed && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Enrichment worker stopped")
+			return
+		case <-time.After(ew.PollInterval):
+		}
+	}
+}
+
+// processNext leases and handles a single job. It reports false when there
+// was nothing to do.
+func (ew *EnrichmentWorker) processNext(ctx context.Context) (bool, error) {
+	job, err := ew.cfg.DB.LeaseEnrichmentJob(ctx, ew.LeaseDuration.Seconds())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	logger := ew.cfg.Logger.WithFields(logrus.Fields{
+		"job_id":   job.ID,
+		"song_id":  job.SongID,
+		"attempts": job.Attempts,
+	})
+	logger.Debug("Leased enrichment job")
+
+	details, err := ew.cfg.SongInfo.FetchSongInfo(ctx, job.GroupName, job.SongName)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Shutting down: leave the lease to expire so another worker
+			// retries the job without counting this attempt as a failure.
+			return true, ctx.Err()
+		}
+		logger.WithError(err).Warn("Failed to fetch song details for enrichment")
+		return true, ew.recordFailure(ctx, job, err)
+	}
+
+	tx, err := ew.cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return true, err
+	}
+	defer tx.Rollback()
+	qtx := ew.cfg.DB.WithTx(tx)
+
+	releaseDate, text, link := songDetailsColumns(details)
+	enriched, err := qtx.CompleteSongEnrichment(ctx, database.CompleteSongEnrichmentParams{
+		ID:          job.SongID,
+		ReleaseDate: releaseDate,
+		Text:        text,
+		Link:        link,
+	})
+	if err != nil {
+		return true, err
+	}
+	if enriched == 0 {
+		logger.Debug("Song is no longer pending, dropping enrichment job")
+	}
+	if err := qtx.DeleteEnrichmentJob(ctx, job.ID); err != nil {
+		return true, err
+	}
+	if err := tx.Commit(); err != nil {
+		return true, err
+	}
+
+	logger.Info("Song enriched successfully")
+	return true, nil
+}
+
+func (ew *EnrichmentWorker) recordFailure(ctx context.Context, job database.LeaseEnrichmentJobRow, fetchErr error) error {
+	lastError := sql.NullString{String: fetchErr.Error(), Valid: true}
+
+	if errors.Is(fetchErr, ErrSongInfoNotFound) || int(job.Attempts) >= ew.MaxAttempts {
+		tx, err := ew.cfg.Conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		qtx := ew.cfg.DB.WithTx(tx)
+
+		if err := qtx.FailEnrichmentJob(ctx, database.FailEnrichmentJobParams{
+			ID:        job.ID,
+			LastError: lastError,
+		}); err != nil {
+			return err
+		}
+		if err := qtx.SetSongEnrichmentStatus(ctx, database.SetSongEnrichmentStatusParams{
+			ID:               job.SongID,
+			EnrichmentStatus: EnrichmentFailed,
+		}); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		ew.cfg.Logger.WithFields(logrus.Fields{
+			"job_id":   job.ID,
+			"song_id":  job.SongID,
+			"attempts": job.Attempts,
+		}).Warn("Enrichment job failed permanently")
+		return nil
+	}
+
+	delay := ew.Retry.backoff(int(job.Attempts) - 1)
+	var openErr *CircuitOpenError
+	if errors.As(fetchErr, &openErr) && openErr.RetryAfter > delay {
+		delay = openErr.RetryAfter
+	}
+
+	return ew.cfg.DB.RescheduleEnrichmentJob(ctx, database.RescheduleEnrichmentJobParams{
+		ID:        job.ID,
+		LastError: lastError,
+		RunAfter:  time.Now().Add(delay),
+	})
+}
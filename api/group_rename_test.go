@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose"
+	"github.com/sirupsen/logrus"
+
+	"github.com/par1ram/song-library/internal/database"
+)
+
+// testDB connects to the PostgreSQL database in TEST_DB_URL and applies
+// the migrations. Tests that need it are skipped when it is not set.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := goose.Up(db, "../sql/schema"); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	return db
+}
+
+func TestRenameGroupInvalidatesSongETag(t *testing.T) {
+	db := testDB(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &ApiConfig{DB: database.New(db), Conn: db, Logger: logger}
+
+	ctx := context.Background()
+	name := fmt.Sprintf("Rename test %d", time.Now().UnixNano())
+	group, err := cfg.DB.InsertGroup(ctx, name)
+	if err != nil {
+		t.Fatalf("InsertGroup: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM songs WHERE group_id = $1`, group.ID)
+		db.Exec(`DELETE FROM groups WHERE id = $1`, group.ID)
+	})
+	var songID int32
+	err = db.QueryRowContext(ctx, `INSERT INTO songs (group_id, song_name) VALUES ($1, 'Starlight') RETURNING id`, group.ID).Scan(&songID)
+	if err != nil {
+		t.Fatalf("insert song: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/songs/{id}", cfg.GetSong)
+	router.Put("/groups/{id}", cfg.RenameGroup)
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	get := func(etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/songs/%d", songID), nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		return serve(r)
+	}
+
+	cached := get("")
+	etag := cached.Header().Get("ETag")
+	if cached.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET = %d with ETag %q, want 200 with an ETag", cached.Code, etag)
+	}
+	if w := get(etag); w.Code != http.StatusNotModified {
+		t.Fatalf("GET with the current ETag = %d, want 304", w.Code)
+	}
+
+	body := fmt.Sprintf(`{"group_name": %q}`, name+" renamed")
+	if w := serve(httptest.NewRequest(http.MethodPut, fmt.Sprintf("/groups/%d", group.ID), strings.NewReader(body))); w.Code != http.StatusOK {
+		t.Fatalf("rename = %d: %s", w.Code, w.Body)
+	}
+
+	fresh := get(etag)
+	if fresh.Code != http.StatusOK {
+		t.Fatalf("GET with the ETag from before the rename = %d, want 200", fresh.Code)
+	}
+	if got := fresh.Header().Get("ETag"); got == etag {
+		t.Errorf("ETag %s did not change with the group name", got)
+	}
+	var song SongResponse
+	if err := json.NewDecoder(fresh.Body).Decode(&song); err != nil {
+		t.Fatalf("decode song: %v", err)
+	}
+	if song.GroupName != name+" renamed" {
+		t.Errorf("group_name = %q, want %q", song.GroupName, name+" renamed")
+	}
+}
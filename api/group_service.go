@@ -0,0 +1,264 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/par1ram/song-library/common"
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+type groupResponse struct {
+	ID        int32  `json:"id"`
+	GroupName string `json:"group_name"`
+}
+
+func newGroupResponse(group database.Group) groupResponse {
+	return groupResponse{
+		ID:        group.ID,
+		GroupName: group.GroupName,
+	}
+}
+
+type GroupRequest struct {
+	GroupName string `json:"group_name" validate:"required,max=255"`
+}
+
+func (cfg *ApiConfig) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("CreateGroup called")
+
+	var req GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		cfg.Logger.WithError(err).Error("Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
+		return
+	}
+
+	if !cfg.validateRequest(w, r, req) {
+		return
+	}
+	groupName := strings.TrimSpace(req.GroupName)
+
+	group, err := cfg.DB.InsertGroup(r.Context(), groupName)
+	if err != nil {
+		if isUniqueViolation(err) {
+			cfg.Logger.WithField("group", groupName).Warn("Group already exists")
+			apierr.Write(w, r, apierr.NewConflict("Group already exists"))
+			return
+		}
+		cfg.Logger.WithError(err).Error("Failed to insert group")
+		apierr.Write(w, r, apierr.NewInternal("Failed to insert group", err))
+		return
+	}
+
+	cfg.Logger.WithField("group_id", group.ID).Info("Group inserted successfully")
+	common.RespondWithJSON(w, http.StatusCreated, newGroupResponse(group))
+}
+
+func (cfg *ApiConfig) ListGroups(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("ListGroups called")
+
+	limit, err := parseQueryInt32(r, "limit", 10)
+	if err != nil || limit <= 0 {
+		cfg.Logger.WithError(err).Error("Invalid limit")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid limit"))
+		return
+	}
+
+	offset, err := parseQueryInt32(r, "offset", 0)
+	if err != nil || offset < 0 {
+		cfg.Logger.WithError(err).Error("Invalid offset")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid offset"))
+		return
+	}
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"limit":  limit,
+		"offset": offset,
+	}).Debug("Querying database for groups")
+
+	groups, err := cfg.DB.ListGroups(r.Context(), database.ListGroupsParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to fetch groups from database")
+		apierr.Write(w, r, apierr.NewInternal("Failed to fetch groups", err))
+		return
+	}
+
+	result := make([]groupResponse, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, newGroupResponse(group))
+	}
+
+	cfg.Logger.WithField("group_count", len(result)).Info("Fetched groups successfully")
+	common.RespondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *ApiConfig) GetGroup(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("GetGroup called")
+
+	groupID, err := routeID(r)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Received invalid group ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid group ID"))
+		return
+	}
+
+	group, err := cfg.DB.GetGroupByID(r.Context(), groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.Logger.WithField("group_id", groupID).Warn("Group not found")
+			apierr.Write(w, r, apierr.NewNotFound("Group not found"))
+			return
+		}
+		cfg.Logger.WithError(err).Error("Failed to fetch group from database")
+		apierr.Write(w, r, apierr.NewInternal("Failed to fetch group", err))
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, newGroupResponse(group))
+}
+
+func (cfg *ApiConfig) RenameGroup(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("RenameGroup called")
+
+	groupID, err := routeID(r)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Received invalid group ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid group ID"))
+		return
+	}
+
+	var req GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		cfg.Logger.WithError(err).Error("Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
+		return
+	}
+
+	if !cfg.validateRequest(w, r, req) {
+		return
+	}
+	groupName := strings.TrimSpace(req.GroupName)
+
+	group, err := cfg.DB.UpdateGroupName(r.Context(), database.UpdateGroupNameParams{
+		ID:        groupID,
+		GroupName: groupName,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.Logger.WithField("group_id", groupID).Warn("Group not found")
+			apierr.Write(w, r, apierr.NewNotFound("Group not found"))
+			return
+		}
+		if isUniqueViolation(err) {
+			cfg.Logger.WithField("group", groupName).Warn("Group already exists")
+			apierr.Write(w, r, apierr.NewConflict("Group already exists"))
+			return
+		}
+		cfg.Logger.WithError(err).Error("Failed to rename group")
+		apierr.Write(w, r, apierr.NewInternal("Failed to rename group", err))
+		return
+	}
+
+	cfg.Logger.WithField("group_id", group.ID).Info("Group renamed successfully")
+	common.RespondWithJSON(w, http.StatusOK, newGroupResponse(group))
+}
+
+// DeleteGroup refuses to delete a group that still has songs unless the
+// caller passes cascade=true, in which case the songs go with it.
+func (cfg *ApiConfig) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("DeleteGroup called")
+
+	groupID, err := routeID(r)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Received invalid group ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid group ID"))
+		return
+	}
+
+	cascade, err := parseQueryBool(r, "cascade", false)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid cascade flag")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid cascade flag"))
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to begin transaction")
+		apierr.Write(w, r, apierr.NewInternal("Failed to delete group", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	songCount, err := qtx.CountSongsByGroupID(r.Context(), groupID)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to count group songs")
+		apierr.Write(w, r, apierr.NewInternal("Failed to delete group", err))
+		return
+	}
+
+	var deletedSongs int64
+	if songCount > 0 {
+		if !cascade {
+			cfg.Logger.WithFields(logrus.Fields{
+				"group_id":   groupID,
+				"song_count": songCount,
+			}).Warn("Group still has songs")
+			apierr.Write(w, r, apierr.NewConflict("Group still has songs, pass cascade=true to delete them too"))
+			return
+		}
+
+		deletedSongs, err = qtx.DeleteSongsByGroupID(r.Context(), groupID)
+		if err != nil {
+			cfg.Logger.WithError(err).Error("Failed to delete group songs")
+			apierr.Write(w, r, apierr.NewInternal("Failed to delete group", err))
+			return
+		}
+	}
+
+	deleted, err := qtx.DeleteGroup(r.Context(), groupID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			cfg.Logger.WithField("group_id", groupID).Warn("Group got new songs while deleting")
+			message := "Group still has songs, pass cascade=true to delete them too"
+			if cascade {
+				message = "Group gained songs during delete, retry"
+			}
+			apierr.Write(w, r, apierr.Wrap(apierr.Conflict, message, err))
+			return
+		}
+		cfg.Logger.WithError(err).Error("Failed to delete group")
+		apierr.Write(w, r, apierr.NewInternal("Failed to delete group", err))
+		return
+	}
+	if deleted == 0 {
+		cfg.Logger.WithField("group_id", groupID).Warn("Group not found")
+		apierr.Write(w, r, apierr.NewNotFound("Group not found"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		cfg.Logger.WithError(err).Error("Failed to commit transaction")
+		apierr.Write(w, r, apierr.NewInternal("Failed to delete group", err))
+		return
+	}
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"group_id":      groupID,
+		"deleted_songs": deletedSongs,
+	}).Info("Group successfully deleted")
+	common.RespondWithJSON(w, http.StatusOK, map[string]any{
+		"message":       "Group successfully deleted",
+		"deleted_songs": deletedSongs,
+	})
+}
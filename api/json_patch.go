@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+)
+
+const jsonPatchMediaType = "application/json-patch+json"
+
+// JSONPatchOperation is one operation of a JSON Patch (RFC 6902).
+type JSONPatchOperation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	// Value is nil when the member is absent and "null" for an explicit
+	// null.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// songPatchDocument is the song as seen by a JSON Patch: only the members
+// below can be patched.
+type songPatchDocument struct {
+	GroupID     int32   `json:"group_id"`
+	SongName    string  `json:"song_name"`
+	Text        *string `json:"text"`
+	ReleaseDate *string `json:"release_date"`
+	Link        *string `json:"link"`
+}
+
+// decodeJSONPatch reads a JSON Patch document, which must be an array.
+func decodeJSONPatch(data []byte) ([]JSONPatchOperation, error) {
+	var ops []JSONPatchOperation
+	if err := json.Unmarshal(data, &ops); err != nil || ops == nil {
+		return nil, apierr.NewBadRequest("Invalid JSON Patch document, expected an array of operations")
+	}
+	return ops, nil
+}
+
+// applyJSONPatch applies ops to the song in order and returns the result
+// as a merge patch of the members that changed. Either all operations
+// apply or none do.
+func applyJSONPatch(row database.GetSongByIDRow, ops []JSONPatchOperation) (PatchSongRequest, error) {
+	data, _ := json.Marshal(songPatchDocument{
+		GroupID:     row.GroupID,
+		SongName:    row.SongName,
+		Text:        nullStringToPtr(row.Text),
+		ReleaseDate: nullDateToPtr(row.ReleaseDate),
+		Link:        nullStringToPtr(row.Link),
+	})
+	var original map[string]json.RawMessage
+	if err := json.Unmarshal(data, &original); err != nil {
+		return PatchSongRequest{}, apierr.NewInternal("Failed to patch song", err)
+	}
+
+	doc := make(map[string]json.RawMessage, len(original))
+	for name, value := range original {
+		doc[name] = value
+	}
+
+	for i, op := range ops {
+		name, err := patchMember(doc, op.Path)
+		if err != nil {
+			return PatchSongRequest{}, jsonPatchError(i, err)
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			if op.Value == nil {
+				return PatchSongRequest{}, jsonPatchError(i, errors.New("value is required"))
+			}
+			doc[name] = op.Value
+		case "remove":
+			doc[name] = json.RawMessage("null")
+		case "move", "copy":
+			from, err := patchMember(doc, op.From)
+			if err != nil {
+				return PatchSongRequest{}, jsonPatchError(i, err)
+			}
+			doc[name] = doc[from]
+			if op.Op == "move" && from != name {
+				doc[from] = json.RawMessage("null")
+			}
+		case "test":
+			if op.Value == nil {
+				return PatchSongRequest{}, jsonPatchError(i, errors.New("value is required"))
+			}
+			if !jsonEqual(doc[name], op.Value) {
+				return PatchSongRequest{}, apierr.NewConflict(fmt.Sprintf("JSON Patch operation %d: test of %s failed", i, op.Path))
+			}
+		default:
+			return PatchSongRequest{}, jsonPatchError(i, fmt.Errorf("unknown op %q", op.Op))
+		}
+	}
+
+	// The changed members are decoded one at a time, so a type error can
+	// name its member: PatchField hides it from json.UnmarshalTypeError.
+	var req PatchSongRequest
+	for _, name := range slices.Sorted(maps.Keys(doc)) {
+		if jsonEqual(doc[name], original[name]) {
+			continue
+		}
+		member, _ := json.Marshal(map[string]json.RawMessage{name: doc[name]})
+		if err := json.Unmarshal(member, &req); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return req, apierr.NewValidation(apierr.FieldError{Field: name, Message: "must be of type " + typeErr.Type.String()})
+			}
+			return req, apierr.NewBadRequest("Invalid JSON Patch value")
+		}
+	}
+	return req, nil
+}
+
+// patchMember resolves a JSON Pointer to a member of doc. Nested paths
+// and members that are not part of the document are rejected.
+func patchMember(doc map[string]json.RawMessage, path string) (string, error) {
+	name, ok := strings.CutPrefix(path, "/")
+	if !ok || strings.Contains(name, "/") {
+		return "", fmt.Errorf("path %q cannot be patched", path)
+	}
+	name = strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+	if _, ok := doc[name]; !ok {
+		return "", fmt.Errorf("path %q cannot be patched", path)
+	}
+	return name, nil
+}
+
+func jsonPatchError(i int, err error) error {
+	return apierr.NewUnprocessable(fmt.Sprintf("JSON Patch operation %d: %s", i, err))
+}
+
+// jsonEqual compares two JSON values by meaning rather than spelling.
+func jsonEqual(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	row := database.GetSongByIDRow{
+		ID:          1,
+		GroupID:     7,
+		SongName:    "Supermassive Black Hole",
+		Text:        sql.NullString{String: "Ooh baby", Valid: true},
+		ReleaseDate: sql.NullTime{Time: time.Date(2006, 7, 16, 0, 0, 0, 0, time.UTC), Valid: true},
+	}
+
+	tests := []struct {
+		name string
+		ops  string
+		want PatchSongRequest
+	}{
+		{
+			name: "add",
+			ops:  `[{"op": "add", "path": "/link", "value": "https://example.com"}]`,
+			want: PatchSongRequest{Link: PatchField[string]{Set: true, Value: "https://example.com"}},
+		},
+		{
+			name: "replace",
+			ops:  `[{"op": "replace", "path": "/song_name", "value": "Starlight"}]`,
+			want: PatchSongRequest{SongName: PatchField[string]{Set: true, Value: "Starlight"}},
+		},
+		{
+			name: "replace with an equal value changes nothing",
+			ops:  `[{"op": "replace", "path": "/group_id", "value": 7.0}]`,
+			want: PatchSongRequest{},
+		},
+		{
+			name: "remove clears the member",
+			ops:  `[{"op": "remove", "path": "/text"}]`,
+			want: PatchSongRequest{Text: PatchField[string]{Set: true, Null: true}},
+		},
+		{
+			name: "move clears the source",
+			ops:  `[{"op": "move", "from": "/text", "path": "/link"}]`,
+			want: PatchSongRequest{
+				Text: PatchField[string]{Set: true, Null: true},
+				Link: PatchField[string]{Set: true, Value: "Ooh baby"},
+			},
+		},
+		{
+			name: "move onto itself changes nothing",
+			ops:  `[{"op": "move", "from": "/text", "path": "/text"}]`,
+			want: PatchSongRequest{},
+		},
+		{
+			name: "copy keeps the source",
+			ops:  `[{"op": "copy", "from": "/song_name", "path": "/text"}]`,
+			want: PatchSongRequest{Text: PatchField[string]{Set: true, Value: "Supermassive Black Hole"}},
+		},
+		{
+			name: "test passes before a write",
+			ops: `[{"op": "test", "path": "/release_date", "value": "2006-07-16"},
+				{"op": "replace", "path": "/release_date", "value": "2006-06-19"}]`,
+			want: PatchSongRequest{ReleaseDate: PatchField[string]{Set: true, Value: "2006-06-19"}},
+		},
+		{
+			name: "test of a null member",
+			ops:  `[{"op": "test", "path": "/link", "value": null}]`,
+			want: PatchSongRequest{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := decodeJSONPatch([]byte(tt.ops))
+			if err != nil {
+				t.Fatalf("decodeJSONPatch: %v", err)
+			}
+			got, err := applyJSONPatch(row, ops)
+			if err != nil {
+				t.Fatalf("applyJSONPatch: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyJSONPatch = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	row := database.GetSongByIDRow{ID: 1, GroupID: 7, SongName: "Starlight"}
+
+	tests := []struct {
+		name   string
+		ops    string
+		status int
+		field  string
+	}{
+		{
+			name:   "failed test",
+			ops:    `[{"op": "test", "path": "/song_name", "value": "Uprising"}]`,
+			status: http.StatusConflict,
+		},
+		{
+			name:   "failed test after a write",
+			ops:    `[{"op": "replace", "path": "/song_name", "value": "Uprising"}, {"op": "test", "path": "/song_name", "value": "Starlight"}]`,
+			status: http.StatusConflict,
+		},
+		{
+			name:   "unknown member",
+			ops:    `[{"op": "replace", "path": "/id", "value": 2}]`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			// ~0 unescapes to ~, so this is "song~name", not song_name.
+			name:   "escaped path",
+			ops:    `[{"op": "replace", "path": "/song~0name", "value": "x"}]`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "nested path",
+			ops:    `[{"op": "add", "path": "/text/0", "value": "a"}]`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "unknown from",
+			ops:    `[{"op": "copy", "from": "/group_name", "path": "/text"}]`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "missing value",
+			ops:    `[{"op": "replace", "path": "/text"}]`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "unknown op",
+			ops:    `[{"op": "increment", "path": "/group_id"}]`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "wrong type",
+			ops:    `[{"op": "replace", "path": "/group_id", "value": "seven"}]`,
+			status: http.StatusBadRequest,
+			field:  "group_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := decodeJSONPatch([]byte(tt.ops))
+			if err != nil {
+				t.Fatalf("decodeJSONPatch: %v", err)
+			}
+			_, err = applyJSONPatch(row, ops)
+			if err == nil {
+				t.Fatal("applyJSONPatch succeeded, want an error")
+			}
+			problem := apierr.ProblemFor(err)
+			if problem.Status != tt.status {
+				t.Errorf("status = %d, want %d (err %v)", problem.Status, tt.status, err)
+			}
+			if tt.field != "" && (len(problem.Errors) != 1 || problem.Errors[0].Field != tt.field) {
+				t.Errorf("field errors = %+v, want one for %s", problem.Errors, tt.field)
+			}
+		})
+	}
+}
+
+// Removing a member that cannot be cleared yields a patch that fails
+// validation rather than an error from applyJSONPatch.
+func TestApplyJSONPatchRemoveRequired(t *testing.T) {
+	row := database.GetSongByIDRow{ID: 1, GroupID: 7, SongName: "Starlight"}
+
+	for _, field := range []string{"group_id", "song_name"} {
+		t.Run(field, func(t *testing.T) {
+			req, err := applyJSONPatch(row, []JSONPatchOperation{{Op: "remove", Path: "/" + field}})
+			if err != nil {
+				t.Fatalf("applyJSONPatch: %v", err)
+			}
+			err = validationError(req)
+			var apiErr *apierr.Error
+			if !errors.As(err, &apiErr) || apiErr.Kind != apierr.Validation {
+				t.Fatalf("validationError = %v, want a validation error", err)
+			}
+			want := []apierr.FieldError{{Field: field, Message: "must not be null"}}
+			if !reflect.DeepEqual(apiErr.Fields, want) {
+				t.Errorf("fields = %+v, want %+v", apiErr.Fields, want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONPatch(t *testing.T) {
+	for _, data := range []string{`{"op": "remove", "path": "/text"}`, `null`, `[`} {
+		if _, err := decodeJSONPatch([]byte(data)); apierr.ProblemFor(err).Status != http.StatusBadRequest {
+			t.Errorf("decodeJSONPatch(%s) = %v, want a bad request", data, err)
+		}
+	}
+
+	ops, err := decodeJSONPatch([]byte(`[{"op": "add", "path": "/text", "value": null}]`))
+	if err != nil {
+		t.Fatalf("decodeJSONPatch: %v", err)
+	}
+	if !jsonEqual(ops[0].Value, json.RawMessage("null")) {
+		t.Errorf("value = %s, want an explicit null", ops[0].Value)
+	}
+}
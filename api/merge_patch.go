@@ -0,0 +1,42 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// PatchField is a member of a JSON merge patch (RFC 7396). Set reports
+// whether the member was given at all and Null whether it was an explicit
+// null, which clears the field.
+type PatchField[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// UnmarshalJSON is only called for members present in the document, which
+// is how an absent member is told apart from a null one.
+func (f *PatchField[T]) UnmarshalJSON(data []byte) error {
+	f.Set = true
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		f.Null = true
+		var zero T
+		f.Value = zero
+		return nil
+	}
+	f.Null = false
+	return json.Unmarshal(data, &f.Value)
+}
+
+// FieldValue implements validate.Field.
+func (f PatchField[T]) FieldValue() (any, bool) {
+	if !f.Set || f.Null {
+		return nil, f.Set
+	}
+	return f.Value, true
+}
+
+// Present reports whether the patch sets the field to a value.
+func (f PatchField[T]) Present() bool {
+	return f.Set && !f.Null
+}
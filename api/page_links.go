@@ -0,0 +1,38 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type pageLink struct {
+	Rel string
+	URL string
+}
+
+// linkURL returns the request URI with the given query parameters replaced
+// and everything else, filters included, kept as is.
+func linkURL(r *http.Request, set url.Values) string {
+	query := r.URL.Query()
+	for name, values := range set {
+		query[name] = values
+	}
+
+	link := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return link.RequestURI()
+}
+
+// setLinkHeader writes the links as a single RFC 8288 Link header.
+func setLinkHeader(w http.ResponseWriter, links []pageLink) {
+	if len(links) == 0 {
+		return
+	}
+
+	values := make([]string, 0, len(links))
+	for _, link := range links {
+		values = append(values, fmt.Sprintf("<%s>; rel=%q", link.URL, link.Rel))
+	}
+	w.Header().Set("Link", strings.Join(values, ", "))
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errInvalidID = errors.New("invalid ID")
+
+func parseQueryInt32(r *http.Request, name string, def int32) (int32, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(value), nil
+}
+
+func parseQueryBool(r *http.Request, name string, def bool) (bool, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	return strconv.ParseBool(raw)
+}
+
+// pathID returns the {id} route parameter and whether the route has one.
+func pathID(r *http.Request) (int32, bool, error) {
+	raw := chi.URLParam(r, "id")
+	if raw == "" {
+		return 0, false, nil
+	}
+
+	id, err := parseID(raw)
+	return id, true, err
+}
+
+// routeID reads the ID from the {id} route parameter of routes that have
+// no deprecated alias.
+func routeID(r *http.Request) (int32, error) {
+	return parseID(chi.URLParam(r, "id"))
+}
+
+// resourceID reads the ID from the {id} route parameter, falling back to
+// the id query parameter of the deprecated routes.
+func resourceID(r *http.Request) (int32, error) {
+	if id, ok, err := pathID(r); ok {
+		return id, err
+	}
+	return parseID(r.URL.Query().Get("id"))
+}
+
+// bodyResourceID reconciles the ID of a request body with the {id} route
+// parameter; the deprecated routes only carry the former.
+func bodyResourceID(r *http.Request, bodyID int32) (int32, error) {
+	id, ok, err := pathID(r)
+	if !ok {
+		if bodyID <= 0 {
+			return 0, errInvalidID
+		}
+		return bodyID, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if bodyID != 0 && bodyID != id {
+		return 0, errors.New("ID in body does not match path")
+	}
+	return id, nil
+}
+
+func parseID(raw string) (int32, error) {
+	id, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil || id <= 0 {
+		return 0, errInvalidID
+	}
+	return int32(id), nil
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/par1ram/song-library/common"
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	bulkAtomic     = "atomic"
+	bulkBestEffort = "best_effort"
+
+	maxBulkOperations = 1000
+
+	bulkSavepoint = "bulk_operation"
+)
+
+// BulkSongRequest is a list of song writes run in one transaction. In the
+// atomic mode (the default) the first failure rolls back everything; in
+// the best_effort mode only the failed operation is rolled back.
+type BulkSongRequest struct {
+	Mode       string              `json:"mode" validate:"oneof=atomic best_effort"`
+	Operations []BulkSongOperation `json:"operations"`
+}
+
+// BulkSongOperation is an update, patch or delete of the song ID or of all
+// songs matching Filter. An update needs an ID and the full Song; Patch is
+// a JSON merge patch object or a JSON Patch array. IfMatch works like the
+// If-Match header and only applies to an operation on an ID.
+type BulkSongOperation struct {
+	Op      string             `json:"op"`
+	ID      int32              `json:"id,omitempty"`
+	Filter  *SongFilterRequest `json:"filter,omitempty"`
+	Song    *UpdateSongRequest `json:"song,omitempty"`
+	Patch   json.RawMessage    `json:"patch,omitempty"`
+	IfMatch string             `json:"if_match,omitempty"`
+}
+
+type BulkSongResult struct {
+	Index int `json:"index"`
+	// Status is ok or failed. After an atomic failure the operations
+	// before it are rolled_back and the ones after it skipped.
+	Status string          `json:"status"`
+	IDs    []int32         `json:"ids,omitempty"`
+	Error  *apierr.Problem `json:"error,omitempty"`
+}
+
+type BulkSongResponse struct {
+	Mode      string           `json:"mode"`
+	Committed bool             `json:"committed"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkSongResult `json:"results"`
+}
+
+func (cfg *ApiConfig) BulkSongs(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("BulkSongs called")
+
+	var req BulkSongRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		cfg.Logger.WithError(err).Error("Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
+		return
+	}
+	if !cfg.validateRequest(w, r, req) {
+		return
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > maxBulkOperations {
+		apierr.Write(w, r, apierr.NewValidation(apierr.FieldError{
+			Field:   "operations",
+			Message: fmt.Sprintf("must hold 1 to %d operations", maxBulkOperations),
+		}))
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = bulkAtomic
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to begin transaction")
+		apierr.Write(w, r, apierr.NewInternal("Failed to run bulk operations", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	resp := BulkSongResponse{
+		Mode:    req.Mode,
+		Results: make([]BulkSongResult, len(req.Operations)),
+	}
+	status := http.StatusOK
+	for i, op := range req.Operations {
+		result := BulkSongResult{Index: i, Status: "ok"}
+		if resp.Failed > 0 && req.Mode == bulkAtomic {
+			result.Status = "skipped"
+			resp.Results[i] = result
+			continue
+		}
+
+		if req.Mode == bulkBestEffort {
+			if err := qtx.Savepoint(r.Context(), bulkSavepoint); err != nil {
+				cfg.Logger.WithError(err).Error("Failed to create savepoint")
+				apierr.Write(w, r, apierr.NewInternal("Failed to run bulk operations", err))
+				return
+			}
+		}
+
+		ids, err := runBulkSongOperation(r.Context(), qtx, op, cfg.RequireIfMatch)
+		if err != nil {
+			cfg.Logger.WithError(err).WithField("index", i).Warn("Bulk operation failed")
+			problem := apierr.ProblemFor(err)
+			result.Status = "failed"
+			result.Error = &problem
+			resp.Failed++
+			if status == http.StatusOK {
+				status = problem.Status
+			}
+		} else {
+			result.IDs = ids
+			resp.Succeeded++
+		}
+		resp.Results[i] = result
+
+		if req.Mode == bulkBestEffort {
+			release := qtx.ReleaseSavepoint
+			if err != nil {
+				release = qtx.RollbackToSavepoint
+			}
+			if err := release(r.Context(), bulkSavepoint); err != nil {
+				cfg.Logger.WithError(err).Error("Failed to release savepoint")
+				apierr.Write(w, r, apierr.NewInternal("Failed to run bulk operations", err))
+				return
+			}
+		}
+	}
+
+	if req.Mode == bulkAtomic && resp.Failed > 0 {
+		for i := range resp.Results {
+			if resp.Results[i].Status == "ok" {
+				resp.Results[i].Status = "rolled_back"
+			}
+		}
+		resp.Succeeded = 0
+		cfg.Logger.WithField("failed", resp.Failed).Warn("Bulk operations rolled back")
+		common.RespondWithJSON(w, status, resp)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		cfg.Logger.WithError(err).Error("Failed to commit transaction")
+		apierr.Write(w, r, apierr.NewInternal("Failed to run bulk operations", err))
+		return
+	}
+	resp.Committed = true
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"succeeded": resp.Succeeded,
+		"failed":    resp.Failed,
+	}).Info("Bulk operations completed")
+	common.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// runBulkSongOperation runs op on q and returns the ids of the songs it
+// changed. An operation on a filter fails as a whole if any song fails.
+// With requireIfMatch set an operation on an ID needs IfMatch.
+func runBulkSongOperation(ctx context.Context, q *database.Queries, op BulkSongOperation, requireIfMatch bool) ([]int32, error) {
+	if op.IfMatch != "" && op.Filter != nil {
+		return nil, bulkFieldError("if_match", "only applies to an operation with an id")
+	}
+	if op.IfMatch == "" && op.ID != 0 && op.Filter == nil && requireIfMatch {
+		return nil, apierr.New(apierr.PreconditionRequired, "if_match with the song ETag is required")
+	}
+	versions := parseIfMatch(op.IfMatch)
+
+	var apply func(id int32) error
+	switch op.Op {
+	case "update":
+		if op.Song == nil || op.Filter != nil {
+			return nil, bulkFieldError("song", "an update needs an id and a song")
+		}
+		if op.Song.ID != 0 && op.Song.ID != op.ID {
+			return nil, bulkFieldError("song", "id does not match the operation id")
+		}
+		apply = func(id int32) error {
+			song := *op.Song
+			song.ID = id
+			_, err := updateSong(ctx, q, song, versions)
+			return err
+		}
+	case "patch":
+		patch := bytes.TrimSpace(op.Patch)
+		switch {
+		case bytes.HasPrefix(patch, []byte("[")):
+			ops, err := decodeJSONPatch(patch)
+			if err != nil {
+				return nil, err
+			}
+			apply = func(id int32) error {
+				_, err := jsonPatchSong(ctx, q, id, ops, versions)
+				return err
+			}
+		case bytes.HasPrefix(patch, []byte("{")):
+			var req PatchSongRequest
+			if err := json.Unmarshal(patch, &req); err != nil {
+				return nil, bulkFieldError("patch", "must be a valid merge patch")
+			}
+			apply = func(id int32) error {
+				_, err := patchSong(ctx, q, id, req, versions)
+				return err
+			}
+		default:
+			return nil, bulkFieldError("patch", "must be a merge patch object or a JSON Patch array")
+		}
+	case "delete":
+		apply = func(id int32) error {
+			return deleteSong(ctx, q, id, versions)
+		}
+	default:
+		return nil, bulkFieldError("op", "must be one of: update, patch, delete")
+	}
+
+	ids, err := bulkSongTargets(ctx, q, op)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := apply(id); err != nil {
+			var apiErr *apierr.Error
+			if op.Filter != nil && errors.As(err, &apiErr) {
+				apiErr.With("song_id", id)
+			}
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// bulkSongTargets returns the song op.ID or the songs matching op.Filter,
+// which must not be empty.
+func bulkSongTargets(ctx context.Context, q *database.Queries, op BulkSongOperation) ([]int32, error) {
+	if (op.ID == 0) == (op.Filter == nil) {
+		return nil, bulkFieldError("id", "exactly one of id and filter is required")
+	}
+	if op.Filter == nil {
+		if op.ID < 0 {
+			return nil, bulkFieldError("id", "must be at least 1")
+		}
+		return []int32{op.ID}, nil
+	}
+
+	filter, err := op.Filter.filter()
+	if err != nil {
+		return nil, bulkFieldError("filter", err.Error())
+	}
+	if filter.IsEmpty() {
+		return nil, bulkFieldError("filter", "must select songs by at least one criterion")
+	}
+
+	ids, err := q.LockSongIDs(ctx, filter)
+	if err != nil {
+		return nil, apierr.NewInternal("Failed to fetch songs", err)
+	}
+	return ids, nil
+}
+
+func bulkFieldError(field, message string) error {
+	return apierr.NewValidation(apierr.FieldError{Field: field, Message: message})
+}
@@ -3,113 +3,257 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/par1ram/song-library/common"
+	"github.com/par1ram/song-library/internal/apierr"
 	"github.com/par1ram/song-library/internal/database"
+	"github.com/par1ram/song-library/internal/validate"
 	"github.com/sirupsen/logrus"
 )
 
+type InsertSongRequest struct {
+	GroupName   string `json:"group" validate:"required,max=255"`
+	SongName    string `json:"song" validate:"required,max=255"`
+	CreateGroup *bool  `json:"create_group,omitempty"`
+	Async       *bool  `json:"async,omitempty"`
+}
+
+type InsertSongResponse struct {
+	ID               int32  `json:"id"`
+	GroupCreated     bool   `json:"group_created"`
+	EnrichmentStatus string `json:"enrichment_status"`
+}
+
 func (cfg *ApiConfig) InsertSong(w http.ResponseWriter, r *http.Request) {
 	cfg.Logger.Info("InsertSong called")
 
-	var req struct {
-		GroupName string `json:"group"`
-		SongName  string `json:"song"`
-	}
+	var req InsertSongRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		cfg.Logger.WithError(err).Error("Invalid request payload")
-		common.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
+		return
+	}
+	req.GroupName = strings.TrimSpace(req.GroupName)
+	req.SongName = strings.TrimSpace(req.SongName)
+	if !cfg.validateRequest(w, r, req) {
 		return
 	}
 
+	createGroup := cfg.AutoCreateGroups
+	if req.CreateGroup != nil {
+		createGroup = *req.CreateGroup
+	}
+
+	async := cfg.AsyncEnrichment
+	if req.Async != nil {
+		async = *req.Async
+	}
+
 	cfg.Logger.WithFields(logrus.Fields{
-		"group": req.GroupName,
-		"song":  req.SongName,
+		"group":        req.GroupName,
+		"song":         req.SongName,
+		"create_group": createGroup,
+		"async":        async,
 	}).Debug("Decoded request payload")
 
-	groupID, err := cfg.DB.GetGroupIDByGroupName(r.Context(), req.GroupName)
+	_, err := cfg.DB.GetGroupIDByGroupName(r.Context(), req.GroupName)
 	if err != nil {
-		cfg.Logger.WithError(err).WithField("group", req.GroupName).Error("Group not found")
-		common.RespondWithError(w, http.StatusNotFound, "Group not found")
-		return
+		if !errors.Is(err, sql.ErrNoRows) {
+			cfg.Logger.WithError(err).Error("Failed to look up group")
+			apierr.Write(w, r, apierr.NewInternal("Failed to look up group", err))
+			return
+		}
+		if !createGroup {
+			cfg.respondGroupNotFound(w, r, req.GroupName)
+			return
+		}
+		cfg.Logger.WithField("group", req.GroupName).Debug("Group not found, it will be created")
 	}
 
-	externalApiURL := common.GetExternalApiURL()
-	reqURL := externalApiURL + "/info?group=" + req.GroupName + "&song=" + req.SongName
-	cfg.Logger.WithField("url", reqURL).Debug("Fetching external API details")
+	// In async mode the song is stored as pending and the details are
+	// filled in later by the EnrichmentWorker.
+	var songDetails SongDetails
+	if !async {
+		cfg.Logger.Debug("Fetching external API details")
+		songDetails, err = cfg.SongInfo.FetchSongInfo(r.Context(), req.GroupName, req.SongName)
+		if err != nil {
+			cfg.respondSongInfoError(w, r, err)
+			return
+		}
+
+		cfg.Logger.WithFields(logrus.Fields{
+			"release_date": songDetails.ReleaseDate,
+			"text":         songDetails.Text,
+			"link":         songDetails.Link,
+		}).Debug("Parsed external API response")
+	}
 
-	resp, err := cfg.HTTPClient.Get(reqURL)
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
 	if err != nil {
-		cfg.Logger.WithError(err).Error("Failed to fetch song details from external API")
-		common.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch song details from external API")
+		cfg.Logger.WithError(err).Error("Failed to begin transaction")
+		apierr.Write(w, r, apierr.NewInternal("Failed to insert song", err))
 		return
 	}
-	defer resp.Body.Close()
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	// The group is resolved again inside the transaction so that a group
+	// created (or deleted) since the lookup above is taken into account.
+	var groupID int32
+	var groupCreated bool
+	if createGroup {
+		group, err := qtx.UpsertGroup(r.Context(), req.GroupName)
+		if err != nil {
+			cfg.Logger.WithError(err).Error("Failed to create group")
+			apierr.Write(w, r, apierr.NewInternal("Failed to create group", err))
+			return
+		}
+		groupID, groupCreated = group.ID, group.Inserted
+	} else {
+		groupID, err = qtx.GetGroupIDByGroupName(r.Context(), req.GroupName)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				cfg.respondGroupNotFound(w, r, req.GroupName)
+				return
+			}
+			cfg.Logger.WithError(err).Error("Failed to look up group")
+			apierr.Write(w, r, apierr.NewInternal("Failed to look up group", err))
+			return
+		}
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		cfg.Logger.WithField("status_code", resp.StatusCode).Error("External API returned an error")
-		common.RespondWithError(w, http.StatusInternalServerError, "External API returned an error")
+	var id int32
+	status := EnrichmentDone
+	if async {
+		status = EnrichmentPending
+		id, err = qtx.InsertPendingSong(r.Context(), database.InsertPendingSongParams{
+			GroupID:  groupID,
+			SongName: req.SongName,
+		})
+		if err == nil {
+			err = qtx.EnqueueEnrichmentJob(r.Context(), id)
+		}
+	} else {
+		releaseDate, text, link := songDetailsColumns(songDetails)
+		id, err = qtx.InsertSong(r.Context(), database.InsertSongParams{
+			GroupID:     groupID,
+			SongName:    req.SongName,
+			ReleaseDate: releaseDate,
+			Text:        text,
+			Link:        link,
+		})
+	}
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to insert song")
+		apierr.Write(w, r, songWriteError("Failed to insert song", err))
 		return
 	}
 
-	var songDetails struct {
-		ReleaseDate string `json:"releaseDate"`
-		Text        string `json:"text"`
-		Link        string `json:"link"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&songDetails); err != nil {
-		cfg.Logger.WithError(err).Error("Failed to parse external API response")
-		common.RespondWithError(w, http.StatusInternalServerError, "Failed to parse external API response")
+	if err := tx.Commit(); err != nil {
+		cfg.Logger.WithError(err).Error("Failed to commit transaction")
+		apierr.Write(w, r, apierr.NewInternal("Failed to insert song", err))
 		return
 	}
 
 	cfg.Logger.WithFields(logrus.Fields{
-		"release_date": songDetails.ReleaseDate,
-		"text":         songDetails.Text,
-		"link":         songDetails.Link,
-	}).Debug("Parsed external API response")
-
-	id, err := cfg.DB.InsertSong(r.Context(), database.InsertSongParams{
-		GroupID:     groupID,
-		SongName:    req.SongName,
-		ReleaseDate: sql.NullTime{Time: parseDate(songDetails.ReleaseDate), Valid: true},
-		Text:        sql.NullString{String: songDetails.Text, Valid: true},
-		Link:        sql.NullString{String: songDetails.Link, Valid: true},
+		"song_id":           id,
+		"group_id":          groupID,
+		"group_created":     groupCreated,
+		"enrichment_status": status,
+	}).Info("Song inserted successfully")
+
+	statusCode := http.StatusCreated
+	if async {
+		statusCode = http.StatusAccepted
+	}
+	common.RespondWithJSON(w, statusCode, InsertSongResponse{
+		ID:               id,
+		GroupCreated:     groupCreated,
+		EnrichmentStatus: status,
 	})
-	if err != nil {
-		cfg.Logger.WithError(err).Error("Failed to insert song")
-		common.RespondWithError(w, http.StatusInternalServerError, "Failed to insert song")
+}
+
+func (cfg *ApiConfig) respondSongInfoError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrSongInfoNotFound) {
+		cfg.Logger.WithError(err).Error("Song not found in external API")
+		apierr.Write(w, r, apierr.NewNotFound("Song not found in external API"))
+		return
+	}
+
+	var openErr *CircuitOpenError
+	if errors.As(err, &openErr) {
+		cfg.Logger.WithError(err).Warn("External API circuit is open")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+		apierr.Write(w, r, apierr.Wrap(apierr.Unavailable, "External API is temporarily unavailable", err))
 		return
 	}
 
-	cfg.Logger.WithField("song_id", id).Info("Song inserted successfully")
-	common.RespondWithJSON(w, http.StatusCreated, map[string]int32{"id": id})
+	cfg.Logger.WithError(err).Error("Failed to fetch song details from external API")
+	apierr.Write(w, r, apierr.Wrap(apierr.Upstream, "Failed to fetch song details from external API", err))
 }
 
-func parseDate(dateStr string) time.Time {
-	layout := "02.01.2006"
-	parsedDate, _ := time.Parse(layout, dateStr)
-	return parsedDate
+// songInfoDateLayouts are the release date formats accepted from the
+// external API, which documents 02.01.2006.
+var songInfoDateLayouts = []string{"02.01.2006", validate.DateLayout}
+
+func parseSongInfoDate(dateStr string) (time.Time, error) {
+	var err error
+	for _, layout := range songInfoDateLayouts {
+		var parsedDate time.Time
+		if parsedDate, err = time.Parse(layout, strings.TrimSpace(dateStr)); err == nil {
+			return parsedDate, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// songDetailsColumns converts external API details to column values. A
+// release date that cannot be parsed is stored as NULL, and like UpdateSong
+// an empty text or link is too.
+func songDetailsColumns(details SongDetails) (sql.NullTime, sql.NullString, sql.NullString) {
+	var releaseDate sql.NullTime
+	if parsedDate, err := parseSongInfoDate(details.ReleaseDate); err == nil {
+		releaseDate = sql.NullTime{Time: parsedDate, Valid: true}
+	}
+
+	return releaseDate,
+		sql.NullString{String: details.Text, Valid: details.Text != ""},
+		sql.NullString{String: details.Link, Valid: details.Link != ""}
+}
+
+type UpdateSongRequest struct {
+	ID          int32  `json:"id"`
+	GroupID     int32  `json:"group_id" validate:"required,min=1"`
+	SongName    string `json:"song_name" validate:"required,max=255"`
+	Text        string `json:"text" validate:"max=100000"`
+	ReleaseDate string `json:"release_date" validate:"date"`
+	Link        string `json:"link" validate:"url,max=2048"`
 }
 
 func (cfg *ApiConfig) UpdateSong(w http.ResponseWriter, r *http.Request) {
 	cfg.Logger.Info("UpdateSong called")
-	var req struct {
-		ID          int32  `json:"id"`
-		GroupID     int32  `json:"group_id"`
-		SongName    string `json:"song_name"`
-		Text        string `json:"text"`
-		ReleaseDate string `json:"release_date"`
-		Link        string `json:"link"`
-	}
+	var req UpdateSongRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		cfg.Logger.WithError(err).Error("Invalid request payload")
-		common.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
+		return
+	}
+
+	id, err := bodyResourceID(r, req.ID)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid song ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid song ID"))
 		return
 	}
+	req.ID = id
 
 	cfg.Logger.WithFields(logrus.Fields{
 		"id":           req.ID,
@@ -118,121 +262,180 @@ func (cfg *ApiConfig) UpdateSong(w http.ResponseWriter, r *http.Request) {
 		"release_date": req.ReleaseDate,
 	}).Debug("Decoded request payload for UpdateSong")
 
-	var releaseDate sql.NullTime
-	if req.ReleaseDate != "" {
-		parsedDate, err := time.Parse("2006-01-02", req.ReleaseDate)
-		if err != nil {
-			cfg.Logger.WithError(err).Error("Invalid date format")
-			common.RespondWithError(w, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD")
-			return
-		}
-		releaseDate = sql.NullTime{Time: parsedDate, Valid: true}
+	versions, err := cfg.songIfMatch(r)
+	if err != nil {
+		cfg.Logger.WithError(err).WithField("song_id", req.ID).Error("Missing If-Match")
+		apierr.Write(w, r, err)
+		return
 	}
 
-	err := cfg.DB.UpdateSong(r.Context(), database.UpdateSongParams{
-		ID:          req.ID,
-		GroupID:     req.GroupID,
-		SongName:    req.SongName,
-		Text:        sql.NullString{String: req.Text, Valid: req.Text != ""},
-		ReleaseDate: releaseDate,
-		Link:        sql.NullString{String: req.Link, Valid: req.Link != ""},
-	})
+	version, err := updateSong(r.Context(), cfg.DB, req, versions)
 	if err != nil {
-		cfg.Logger.WithError(err).Error("Failed to update song")
-		common.RespondWithError(w, http.StatusInternalServerError, "Failed to update song")
+		cfg.Logger.WithError(err).WithField("song_id", req.ID).Error("Failed to update song")
+		apierr.Write(w, r, err)
 		return
 	}
 
 	cfg.Logger.WithField("song_id", req.ID).Info("Song updated successfully")
+	w.Header().Set("ETag", songETag(version))
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// PatchSongRequest is a JSON merge patch (RFC 7396) of a song: absent
+// members keep their value and null clears it. group_id and song_name
+// cannot be cleared.
 type PatchSongRequest struct {
-	GroupID     *int32  `json:"group_id,omitempty"`
-	SongName    *string `json:"song_name,omitempty"`
-	Text        *string `json:"text,omitempty"`
-	ReleaseDate *string `json:"release_date,omitempty"`
-	Link        *string `json:"link,omitempty"`
+	ID          int32              `json:"id,omitempty"`
+	GroupID     PatchField[int32]  `json:"group_id" validate:"notnull,min=1"`
+	SongName    PatchField[string] `json:"song_name" validate:"notnull,notblank,max=255"`
+	Text        PatchField[string] `json:"text" validate:"max=100000"`
+	ReleaseDate PatchField[string] `json:"release_date" validate:"date"`
+	Link        PatchField[string] `json:"link" validate:"url,max=2048"`
+}
+
+// params converts a validated patch to the partial update of the song id.
+// Like UpdateSong it stores an empty text, release_date or link as NULL.
+func (req PatchSongRequest) params(id int32) database.UpdateSongPartialParams {
+	params := database.UpdateSongPartialParams{
+		ID:             id,
+		GroupID:        sql.NullInt32{Int32: req.GroupID.Value, Valid: req.GroupID.Present()},
+		SongName:       sql.NullString{String: strings.TrimSpace(req.SongName.Value), Valid: req.SongName.Present()},
+		SetText:        req.Text.Set,
+		Text:           sql.NullString{String: req.Text.Value, Valid: req.Text.Value != ""},
+		SetReleaseDate: req.ReleaseDate.Set,
+		SetLink:        req.Link.Set,
+		Link:           sql.NullString{String: req.Link.Value, Valid: req.Link.Value != ""},
+	}
+	if req.ReleaseDate.Present() && req.ReleaseDate.Value != "" {
+		parsedDate, _ := time.Parse(validate.DateLayout, req.ReleaseDate.Value)
+		params.ReleaseDate = sql.NullTime{Time: parsedDate, Valid: true}
+	}
+	return params
 }
 
 func (cfg *ApiConfig) PatchSong(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		ID          int32   `json:"id"`
-		GroupID     *int32  `json:"group_id,omitempty"`
-		SongName    *string `json:"song_name,omitempty"`
-		Text        *string `json:"text,omitempty"`
-		ReleaseDate *string `json:"release_date,omitempty"`
-		Link        *string `json:"link,omitempty"`
+	cfg.Logger.Info("PatchSong called")
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == jsonPatchMediaType {
+		cfg.jsonPatchSong(w, r)
+		return
 	}
 
+	var req PatchSongRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		cfg.Logger.WithError(err).Error("Invalid request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		cfg.Logger.WithError(err).Error("Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
 		return
 	}
 
-	if req.ID <= 0 {
-		cfg.Logger.Error("Invalid song ID")
-		http.Error(w, "Invalid song ID", http.StatusBadRequest)
+	id, err := bodyResourceID(r, req.ID)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid song ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid song ID"))
 		return
 	}
+	req.ID = id
 
-	params := database.UpdateSongPartialParams{
-		ID: req.ID,
+	versions, err := cfg.songIfMatch(r)
+	if err != nil {
+		cfg.Logger.WithError(err).WithField("song_id", req.ID).Error("Missing If-Match")
+		apierr.Write(w, r, err)
+		return
 	}
 
-	if req.GroupID != nil {
-		params.GroupID = *req.GroupID
+	version, err := patchSong(r.Context(), cfg.DB, req.ID, req, versions)
+	if err != nil {
+		cfg.Logger.WithError(err).WithField("song_id", req.ID).Error("Failed to update song")
+		apierr.Write(w, r, err)
+		return
 	}
-	if req.SongName != nil {
-		params.SongName = *req.SongName
+
+	w.Header().Set("ETag", songETag(version))
+	w.WriteHeader(http.StatusOK)
+}
+
+// jsonPatchSong is PatchSong for a JSON Patch (RFC 6902) body. The
+// operations apply to the song atomically.
+func (cfg *ApiConfig) jsonPatchSong(w http.ResponseWriter, r *http.Request) {
+	id, err := resourceID(r)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid song ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid song ID"))
+		return
 	}
-	if req.Text != nil {
-		params.Text = sql.NullString{String: *req.Text, Valid: true}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
+		return
 	}
-	if req.ReleaseDate != nil {
-		params.ReleaseDate = sql.NullTime{Time: parseDate(*req.ReleaseDate), Valid: true}
+	ops, err := decodeJSONPatch(data)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid JSON Patch")
+		apierr.Write(w, r, err)
+		return
 	}
-	if req.Link != nil {
-		params.Link = sql.NullString{String: *req.Link, Valid: true}
+	versions, err := cfg.songIfMatch(r)
+	if err != nil {
+		cfg.Logger.WithError(err).WithField("song_id", id).Error("Missing If-Match")
+		apierr.Write(w, r, err)
+		return
 	}
 
-	if err := cfg.DB.UpdateSongPartial(r.Context(), params); err != nil {
-		cfg.Logger.WithError(err).Error("Failed to update song")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to begin transaction")
+		apierr.Write(w, r, apierr.NewInternal("Failed to update song", err))
 		return
 	}
+	defer tx.Rollback()
 
+	version, err := jsonPatchSong(r.Context(), cfg.DB.WithTx(tx), id, ops, versions)
+	if err != nil {
+		cfg.Logger.WithError(err).WithField("song_id", id).Error("Failed to update song")
+		apierr.Write(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		cfg.Logger.WithError(err).Error("Failed to commit transaction")
+		apierr.Write(w, r, apierr.NewInternal("Failed to update song", err))
+		return
+	}
+
+	w.Header().Set("ETag", songETag(version))
 	w.WriteHeader(http.StatusOK)
 }
 
 func (cfg *ApiConfig) DeleteSong(w http.ResponseWriter, r *http.Request) {
 	cfg.Logger.Info("Starting to process delete song request")
 
-	songID, err := strconv.Atoi(r.URL.Query().Get("id"))
-	if err != nil || songID <= 0 {
+	songID, err := resourceID(r)
+	if err != nil {
 		cfg.Logger.WithFields(logrus.Fields{
 			"song_id": songID,
 			"error":   err,
 		}).Error("Received invalid song ID")
-		common.RespondWithError(w, http.StatusBadRequest, "Invalid song ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid song ID"))
+		return
+	}
+
+	versions, err := cfg.songIfMatch(r)
+	if err != nil {
+		cfg.Logger.WithError(err).WithField("song_id", songID).Error("Missing If-Match")
+		apierr.Write(w, r, err)
 		return
 	}
 
 	cfg.Logger.WithField("song_id", songID).Debug("Attempting to delete song")
 
-	err = cfg.DB.DeleteSong(r.Context(), int32(songID))
-	if err != nil {
-		if err == sql.ErrNoRows {
-			cfg.Logger.WithField("song_id", songID).Warn("Song not found")
-			common.RespondWithError(w, http.StatusNotFound, "Song not found")
-			return
-		}
+	if err := deleteSong(r.Context(), cfg.DB, songID, versions); err != nil {
 		cfg.Logger.WithFields(logrus.Fields{
 			"song_id": songID,
 			"error":   err,
 		}).Error("Error deleting song from database")
-		common.RespondWithError(w, http.StatusInternalServerError, "Failed to delete song")
+		apierr.Write(w, r, err)
 		return
 	}
 
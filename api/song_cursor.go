@@ -0,0 +1,75 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/par1ram/song-library/internal/database"
+)
+
+var (
+	errInvalidCursor      = errors.New("Invalid cursor")
+	errCursorSortMismatch = errors.New("Cursor was issued for a different sort")
+)
+
+// songCursor points at a song in a listing. It remembers the sort it was
+// issued for, since the key values only make sense in that order. Before
+// marks a cursor that pages backwards from the song.
+type songCursor struct {
+	Sort   string    `json:"s"`
+	Key    []*string `json:"k"`
+	Before bool      `json:"b,omitempty"`
+}
+
+func newSongCursor(sort string, key database.SongKey, before bool) songCursor {
+	values := make([]*string, 0, len(key))
+	for _, value := range key {
+		values = append(values, nullStringToPtr(value))
+	}
+	return songCursor{
+		Sort:   sort,
+		Key:    values,
+		Before: before,
+	}
+}
+
+// encode returns the cursor as an opaque URL-safe token.
+func (c songCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSongCursor parses a token issued for the given sort keys.
+func decodeSongCursor(token string, keys []database.SongSort) (songCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return songCursor{}, errInvalidCursor
+	}
+
+	var c songCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return songCursor{}, errInvalidCursor
+	}
+	if c.Sort != formatSongSort(keys) {
+		return songCursor{}, errCursorSortMismatch
+	}
+	if !database.ValidSongKey(keys, c.key()) {
+		return songCursor{}, errInvalidCursor
+	}
+
+	return c, nil
+}
+
+func (c songCursor) key() database.SongKey {
+	key := make(database.SongKey, 0, len(c.Key))
+	for _, value := range c.Key {
+		if value == nil {
+			key = append(key, sql.NullString{})
+			continue
+		}
+		key = append(key, sql.NullString{String: *value, Valid: true})
+	}
+	return key
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/par1ram/song-library/internal/database"
+)
+
+func TestSongCursorRoundTrip(t *testing.T) {
+	keys := database.SongSortKeys([]database.SongSort{{Field: "release_date", Desc: true}})
+
+	tests := []struct {
+		name   string
+		key    database.SongKey
+		before bool
+	}{
+		{"after a dated song", database.SongKey{{String: "2006-07-16", Valid: true}, {String: "3", Valid: true}}, false},
+		{"before an undated song", database.SongKey{{}, {String: "3", Valid: true}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := newSongCursor(formatSongSort(keys), tt.key, tt.before).encode()
+			got, err := decodeSongCursor(token, keys)
+			if err != nil {
+				t.Fatalf("decodeSongCursor: %v", err)
+			}
+			if !reflect.DeepEqual(got.key(), tt.key) || got.Before != tt.before {
+				t.Errorf("decoded key %+v before=%v, want %+v before=%v", got.key(), got.Before, tt.key, tt.before)
+			}
+		})
+	}
+}
+
+func TestDecodeSongCursorErrors(t *testing.T) {
+	keys := database.SongSortKeys([]database.SongSort{{Field: "release_date", Desc: true}})
+	sort := formatSongSort(keys)
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	cursor := func(sort string, key ...sql.NullString) string {
+		return newSongCursor(sort, key, false).encode()
+	}
+	valid := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"not base64", "!!!", errInvalidCursor},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"id"}`)), errInvalidCursor},
+		{"not JSON", encode("release_date"), errInvalidCursor},
+		{"other sort", cursor("song_name,id", valid("Starlight"), valid("3")), errCursorSortMismatch},
+		{"same fields in another direction", cursor("release_date,id", valid("2006-07-16"), valid("3")), errCursorSortMismatch},
+		{"missing key value", cursor(sort, valid("2006-07-16")), errInvalidCursor},
+		{"malformed key value", cursor(sort, valid("16.07.2006"), valid("3")), errInvalidCursor},
+		{"null id", cursor(sort, valid("2006-07-16"), sql.NullString{}), errInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeSongCursor(tt.token, keys); !errors.Is(err, tt.want) {
+				t.Errorf("decodeSongCursor error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+// Every update of a song bumps its version, which is served as a strong
+// ETag. Renaming a group bumps the versions of its songs as well. Writes with If-Match only apply to the listed versions, so two
+// editors cannot silently overwrite each other.
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/par1ram/song-library/internal/apierr"
+)
+
+func songETag(version int32) string {
+	return `"` + strconv.Itoa(int(version)) + `"`
+}
+
+// songViewETag is the ETag of a song served by GetSong. A fields or
+// include selection is another representation, so it is hashed into the
+// tag; the tag is weak since writes take the ETag of the full song.
+func songViewETag(version int32, fields []string, includeGroup bool) string {
+	if fields == nil && !includeGroup {
+		return songETag(version)
+	}
+	view := fnv.New32a()
+	view.Write([]byte(strings.Join(fields, ",")))
+	if includeGroup {
+		view.Write([]byte(";group"))
+	}
+	return fmt.Sprintf(`W/"%d-%08x"`, version, view.Sum32())
+}
+
+// errSongModified answers a write whose If-Match no longer holds.
+func errSongModified() *apierr.Error {
+	return apierr.New(apierr.PreconditionFailed, "Song has been modified, fetch it again and retry")
+}
+
+// parseIfMatch returns the song versions an If-Match header lists. It
+// returns nil for an absent header or *, which put no condition on the
+// version, and an empty slice when no listed ETag can match. Weak ETags
+// never match If-Match.
+func parseIfMatch(header string) []int32 {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	versions := []int32{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 32)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, int32(version))
+	}
+	return versions
+}
+
+// songIfMatch returns the versions a write of a song is conditioned on.
+// With RequireIfMatch set a request without If-Match fails with 428.
+func (cfg *ApiConfig) songIfMatch(r *http.Request) ([]int32, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" && cfg.RequireIfMatch {
+		return nil, apierr.New(apierr.PreconditionRequired, "If-Match with the song ETag is required")
+	}
+	return parseIfMatch(header), nil
+}
+
+// etagListed reports whether an If-None-Match header lists etag, using
+// the weak comparison.
+func etagListed(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/par1ram/song-library/internal/apierr"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		// want is nil for an unconditional write and empty for one that
+		// can never match.
+		want []int32
+	}{
+		{"absent", "", nil},
+		{"blank", "  ", nil},
+		{"any", "*", nil},
+		{"any with spaces", " * ", nil},
+		{"one tag", `"3"`, []int32{3}},
+		{"list", `"3", "5"`, []int32{3, 5}},
+		{"list without spaces", `"3","5"`, []int32{3, 5}},
+		{"weak tags never match", `W/"3"`, []int32{}},
+		{"weak tags are skipped in a list", `W/"3", "4"`, []int32{4}},
+		{"unquoted", `3`, []int32{}},
+		{"half quoted", `"3`, []int32{}},
+		{"not a version", `"abc"`, []int32{}},
+		{"out of range", `"2147483648"`, []int32{}},
+		{"empty tag", `""`, []int32{}},
+		{"empty list members", `,"3",`, []int32{3}},
+		{"star in a list", `*, "3"`, []int32{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseIfMatch(tt.header)
+			if (got == nil) != (tt.want == nil) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIfMatch(%q) = %#v, want %#v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSongETagRoundTrip(t *testing.T) {
+	if got := parseIfMatch(songETag(42)); !reflect.DeepEqual(got, []int32{42}) {
+		t.Errorf("parseIfMatch(songETag(42)) = %v, want [42]", got)
+	}
+}
+
+func TestEtagListed(t *testing.T) {
+	etag := songETag(3)
+
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"3"`, true},
+		{`W/"3"`, true},
+		{`"2", "3"`, true},
+		{`"2","3"`, true},
+		{`*`, true},
+		{`"4"`, false},
+		{`W/"4"`, false},
+		{`3`, false},
+		{`"33"`, false},
+		{`w/"3"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := etagListed(tt.header, etag); got != tt.want {
+			t.Errorf("etagListed(%q, %s) = %v, want %v", tt.header, etag, got, tt.want)
+		}
+	}
+}
+
+func TestSongIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		require bool
+		header  string
+		want    []int32
+		status  int
+	}{
+		{name: "optional and absent", header: "", want: nil},
+		{name: "optional and present", header: `"3"`, want: []int32{3}},
+		{name: "required and absent", require: true, header: "", status: http.StatusPreconditionRequired},
+		{name: "required and any", require: true, header: "*", want: nil},
+		{name: "required and present", require: true, header: `"3"`, want: []int32{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &ApiConfig{RequireIfMatch: tt.require}
+			r := httptest.NewRequest(http.MethodPut, "/songs/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+
+			got, err := cfg.songIfMatch(r)
+			if tt.status != 0 {
+				if err == nil || apierr.ProblemFor(err).Status != tt.status {
+					t.Fatalf("songIfMatch error = %v, want status %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("songIfMatch: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("songIfMatch = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSongViewETag(t *testing.T) {
+	full := songViewETag(3, nil, false)
+	if full != songETag(3) {
+		t.Errorf("songViewETag without a selection = %s, want %s", full, songETag(3))
+	}
+
+	views := []struct {
+		fields       []string
+		includeGroup bool
+	}{
+		{[]string{"song"}, false},
+		{[]string{"song", "group"}, false},
+		{[]string{"group", "song"}, false},
+		{nil, true},
+		{[]string{"song"}, true},
+	}
+	seen := map[string]bool{full: true}
+	for _, view := range views {
+		etag := songViewETag(3, view.fields, view.includeGroup)
+		if seen[etag] {
+			t.Errorf("songViewETag(3, %v, %v) = %s, already served for another view", view.fields, view.includeGroup, etag)
+		}
+		seen[etag] = true
+		if !strings.HasPrefix(etag, `W/"3-`) {
+			t.Errorf("songViewETag(3, %v, %v) = %s, want a weak tag of version 3", view.fields, view.includeGroup, etag)
+		}
+		// A view is only good for If-None-Match, never for a write.
+		if got := parseIfMatch(etag); len(got) != 0 {
+			t.Errorf("parseIfMatch(%s) = %v, want no versions", etag, got)
+		}
+		if etagListed(etag, songViewETag(4, view.fields, view.includeGroup)) {
+			t.Errorf("the %v view of version 3 matches version 4", view.fields)
+		}
+	}
+}
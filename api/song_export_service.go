@@ -0,0 +1,274 @@
+package api
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+const exportBatchSize = 500
+
+// exportFormats maps the export formats to their media types.
+var exportFormats = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"json":   "application/json",
+}
+
+// songExportWriter writes songs in one of the export formats.
+type songExportWriter interface {
+	begin() error
+	write(song SongResponse) error
+	end() error
+}
+
+func newSongExportWriter(format string, w *bufio.Writer, fields []string) songExportWriter {
+	switch format {
+	case "csv":
+		return &csvSongWriter{w: csv.NewWriter(w), fields: fields}
+	case "json":
+		return &jsonSongWriter{w: w, array: true}
+	default:
+		return &jsonSongWriter{w: w}
+	}
+}
+
+// jsonSongWriter writes a JSON array, or one object per line for NDJSON.
+type jsonSongWriter struct {
+	w     *bufio.Writer
+	array bool
+	count int
+}
+
+func (j *jsonSongWriter) begin() error {
+	if j.array {
+		return j.w.WriteByte('[')
+	}
+	return nil
+}
+
+func (j *jsonSongWriter) write(song SongResponse) error {
+	data, err := json.Marshal(song)
+	if err != nil {
+		return err
+	}
+	if j.array && j.count > 0 {
+		j.w.WriteByte(',')
+	}
+	j.count++
+	j.w.Write(data)
+	if !j.array {
+		return j.w.WriteByte('\n')
+	}
+	return nil
+}
+
+func (j *jsonSongWriter) end() error {
+	if j.array {
+		return j.w.WriteByte(']')
+	}
+	return nil
+}
+
+type csvSongWriter struct {
+	w      *csv.Writer
+	fields []string
+	record []string
+}
+
+func (c *csvSongWriter) begin() error {
+	return c.w.Write(c.fields)
+}
+
+func (c *csvSongWriter) write(song SongResponse) error {
+	c.record = c.record[:0]
+	for _, field := range c.fields {
+		c.record = append(c.record, song.csvValue(field))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvSongWriter) end() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// csvValue renders an attribute for CSV; NULL becomes an empty cell.
+func (s SongResponse) csvValue(field string) string {
+	optional := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+
+	switch field {
+	case "id":
+		return strconv.Itoa(int(s.ID))
+	case "song_name":
+		return s.SongName
+	case "release_date":
+		return optional(s.ReleaseDate)
+	case "text":
+		return optional(s.Text)
+	case "link":
+		return optional(s.Link)
+	case "group_id":
+		return strconv.Itoa(int(s.GroupID))
+	case "group_name":
+		return s.GroupName
+	case "enrichment_status":
+		return s.EnrichmentStatus
+	case "created_at":
+		return s.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return s.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+// ExportSongs serves GET /export?format=csv|ndjson|json. It takes the
+// filters, sort and fields of GET /songs and streams every matching song,
+// including the lyrics unless fields leaves them out.
+func (cfg *ApiConfig) ExportSongs(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("ExportSongs called")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	contentType, ok := exportFormats[format]
+	if !ok {
+		cfg.Logger.WithField("format", format).Error("Unsupported export format")
+		apierr.Write(w, r, apierr.NewBadRequest("Unsupported format, use: csv, ndjson, json"))
+		return
+	}
+
+	req, err := songFilterFromQuery(r)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid query parameters")
+		apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+		return
+	}
+	filter, err := req.filter()
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid song filter")
+		apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+		return
+	}
+	sort, err := parseSongSort(req.Sort)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid sort")
+		apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+		return
+	}
+	fields, err := parseSongFields(req.Fields)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid fields")
+		apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+		return
+	}
+	if fields == nil {
+		fields = songFields
+	}
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"format": format,
+		"filter": filter,
+		"sort":   req.Sort,
+		"fields": fields,
+	}).Debug("Exporting songs")
+
+	// An export of the whole catalogue outlives the server's WriteTimeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		cfg.Logger.WithError(err).Warn("Failed to lift write deadline")
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to begin transaction")
+		apierr.Write(w, r, apierr.NewInternal("Failed to export songs", err))
+		return
+	}
+	defer tx.Rollback()
+
+	out := bufio.NewWriter(w)
+	writer := newSongExportWriter(format, out, fields)
+	started, exported := false, 0
+	err = cfg.DB.WithTx(tx).ExportSongs(r.Context(), database.ExportSongsParams{
+		Filter:    filter,
+		Sort:      sort,
+		WithText:  slices.Contains(fields, "text"),
+		BatchSize: exportBatchSize,
+	}, func(rows []database.ListSongsPageRow) error {
+		// The response starts with the first batch, so a query that fails
+		// right away still gets a proper error.
+		if !started {
+			startExport(w, format, contentType)
+			started = true
+			if err := writer.begin(); err != nil {
+				return err
+			}
+		}
+		for _, row := range rows {
+			song := newSongResponseFromListRow(row)
+			song.fields = fields
+			if err := writer.write(song); err != nil {
+				return err
+			}
+		}
+		exported += len(rows)
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+	if err != nil && !started {
+		cfg.Logger.WithError(err).Error("Failed to export songs")
+		apierr.Write(w, r, apierr.NewInternal("Failed to export songs", err))
+		return
+	}
+	if err != nil {
+		// The status is gone already. Abort the connection so the client
+		// cannot mistake a truncated dump for a complete one.
+		cfg.Logger.WithError(err).WithField("exported", exported).Error("Export failed midway")
+		panic(http.ErrAbortHandler)
+	}
+
+	if !started {
+		startExport(w, format, contentType)
+		err = writer.begin()
+	}
+	if err == nil {
+		err = writer.end()
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to finish export")
+		panic(http.ErrAbortHandler)
+	}
+
+	cfg.Logger.WithField("song_count", exported).Info("Songs exported")
+}
+
+func startExport(w http.ResponseWriter, format, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="songs.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+}
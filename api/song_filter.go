@@ -0,0 +1,250 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/par1ram/song-library/internal/validate"
+)
+
+// SongFilterRequest holds the listing filters. Group is the single group
+// filter of the deprecated POST /songs/filter body, Groups matches any of
+// several groups. Date filters narrow each other down: release_date,
+// release_from/release_to, year and decade may be combined.
+type SongFilterRequest struct {
+	Group       string   `json:"group"`
+	Groups      []string `json:"groups,omitempty"`
+	GroupIDs    []int32  `json:"group_ids,omitempty"`
+	GroupMatch  string   `json:"group_match,omitempty"`
+	Song        string   `json:"song"`
+	SongMatch   string   `json:"song_match,omitempty"`
+	Text        string   `json:"text,omitempty"`
+	ReleaseDate string   `json:"release_date"`
+	ReleaseFrom string   `json:"release_from,omitempty"`
+	ReleaseTo   string   `json:"release_to,omitempty"`
+	Year        int32    `json:"year,omitempty"`
+	Decade      int32    `json:"decade,omitempty"`
+	HasLink     *bool    `json:"has_link,omitempty"`
+	HasText     *bool    `json:"has_text,omitempty"`
+	Sort        string   `json:"sort,omitempty"`
+	Fields      string   `json:"fields,omitempty"`
+	Include     string   `json:"include,omitempty"`
+	Limit       int32    `json:"limit"`
+	Offset      int32    `json:"offset"`
+	// Cursor switches to keyset pagination; an empty cursor asks for the
+	// first page.
+	Cursor *string `json:"cursor,omitempty"`
+}
+
+// songFilterFromQuery reads the filters of GET /songs. group and group_id
+// may be repeated.
+func songFilterFromQuery(r *http.Request) (SongFilterRequest, error) {
+	query := r.URL.Query()
+	req := SongFilterRequest{
+		Groups:      query["group"],
+		GroupMatch:  query.Get("group_match"),
+		Song:        query.Get("song"),
+		SongMatch:   query.Get("song_match"),
+		Text:        query.Get("text"),
+		ReleaseDate: query.Get("release_date"),
+		ReleaseFrom: query.Get("release_from"),
+		ReleaseTo:   query.Get("release_to"),
+		Sort:        query.Get("sort"),
+		Fields:      query.Get("fields"),
+		Include:     query.Get("include"),
+	}
+	if query.Has("cursor") {
+		cursor := query.Get("cursor")
+		req.Cursor = &cursor
+	}
+
+	for _, raw := range query["group_id"] {
+		id, err := parseID(raw)
+		if err != nil {
+			return req, errors.New("Invalid group_id")
+		}
+		req.GroupIDs = append(req.GroupIDs, id)
+	}
+
+	var err error
+	if req.Year, err = parseQueryInt32(r, "year", 0); err != nil {
+		return req, errors.New("Invalid year")
+	}
+	if req.Decade, err = parseQueryInt32(r, "decade", 0); err != nil {
+		return req, errors.New("Invalid decade")
+	}
+	for _, flag := range []struct {
+		name   string
+		target **bool
+	}{
+		{"has_link", &req.HasLink},
+		{"has_text", &req.HasText},
+	} {
+		if !query.Has(flag.name) {
+			continue
+		}
+		value, err := parseQueryBool(r, flag.name, false)
+		if err != nil {
+			return req, fmt.Errorf("Invalid %s flag", flag.name)
+		}
+		*flag.target = &value
+	}
+	if req.Limit, err = parseQueryInt32(r, "limit", 0); err != nil {
+		return req, errors.New("Invalid limit")
+	}
+	if req.Offset, err = parseQueryInt32(r, "offset", 0); err != nil {
+		return req, errors.New("Invalid offset")
+	}
+
+	return req, nil
+}
+
+func parseMatchMode(raw, name string) (database.MatchMode, error) {
+	switch mode := database.MatchMode(raw); mode {
+	case "":
+		return database.MatchContains, nil
+	case database.MatchContains, database.MatchPrefix, database.MatchExact:
+		return mode, nil
+	}
+	return "", fmt.Errorf("Invalid %s, use exact, prefix or contains", name)
+}
+
+// parseSongSort reads a comma separated list of sort fields, each one
+// optionally prefixed with - for descending order, e.g. -release_date,id.
+// Without fields the newest releases come first.
+func parseSongSort(raw string) ([]database.SongSort, error) {
+	if strings.TrimSpace(raw) == "" {
+		return []database.SongSort{{Field: "release_date", Desc: true}}, nil
+	}
+
+	var sort []database.SongSort
+	seen := make(map[string]bool)
+	for _, term := range strings.Split(raw, ",") {
+		term = strings.TrimSpace(term)
+		key := database.SongSort{Field: strings.TrimPrefix(term, "-"), Desc: strings.HasPrefix(term, "-")}
+		if !slices.Contains(database.SongSortFields, key.Field) {
+			return nil, fmt.Errorf("Unknown sort field %q, allowed: %s", key.Field, strings.Join(database.SongSortFields, ", "))
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("Duplicate sort field %q", key.Field)
+		}
+		seen[key.Field] = true
+		sort = append(sort, key)
+	}
+
+	return sort, nil
+}
+
+func formatSongSort(sort []database.SongSort) string {
+	terms := make([]string, 0, len(sort))
+	for _, key := range sort {
+		if key.Desc {
+			terms = append(terms, "-"+key.Field)
+		} else {
+			terms = append(terms, key.Field)
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// dateRange is an inclusive range of release dates, unbounded on a side
+// whose bound is not valid.
+type dateRange struct {
+	from, to sql.NullTime
+}
+
+func (d *dateRange) narrowFrom(from time.Time) {
+	if !d.from.Valid || from.After(d.from.Time) {
+		d.from = sql.NullTime{Time: from, Valid: true}
+	}
+}
+
+func (d *dateRange) narrowTo(to time.Time) {
+	if !d.to.Valid || to.Before(d.to.Time) {
+		d.to = sql.NullTime{Time: to, Valid: true}
+	}
+}
+
+// narrowYears limits the range to the years [first, first+years).
+func (d *dateRange) narrowYears(first, years int32) {
+	start := time.Date(int(first), time.January, 1, 0, 0, 0, 0, time.UTC)
+	d.narrowFrom(start)
+	d.narrowTo(start.AddDate(int(years), 0, -1))
+}
+
+// filter validates the request and turns it into a database filter. The
+// error messages are meant for the client.
+func (req SongFilterRequest) filter() (database.SongFilter, error) {
+	filter := database.SongFilter{
+		GroupIDs: req.GroupIDs,
+		SongName: strings.TrimSpace(req.Song),
+		Text:     strings.TrimSpace(req.Text),
+	}
+
+	for _, group := range append([]string{req.Group}, req.Groups...) {
+		if group = strings.TrimSpace(group); group != "" {
+			filter.GroupNames = append(filter.GroupNames, group)
+		}
+	}
+
+	var err error
+	if filter.GroupMatch, err = parseMatchMode(req.GroupMatch, "group_match"); err != nil {
+		return filter, err
+	}
+	if filter.SongMatch, err = parseMatchMode(req.SongMatch, "song_match"); err != nil {
+		return filter, err
+	}
+
+	var dates dateRange
+	for _, bound := range []struct {
+		name, value string
+		from, to    bool
+	}{
+		{"release_date", req.ReleaseDate, true, true},
+		{"release_from", req.ReleaseFrom, true, false},
+		{"release_to", req.ReleaseTo, false, true},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		date, err := time.Parse(validate.DateLayout, bound.value)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid %s, use YYYY-MM-DD", bound.name)
+		}
+		if bound.from {
+			dates.narrowFrom(date)
+		}
+		if bound.to {
+			dates.narrowTo(date)
+		}
+	}
+	if req.Year != 0 {
+		if req.Year < 1 || req.Year > 9999 {
+			return filter, errors.New("Invalid year")
+		}
+		dates.narrowYears(req.Year, 1)
+	}
+	if req.Decade != 0 {
+		if req.Decade < 0 || req.Decade > 9990 || req.Decade%10 != 0 {
+			return filter, errors.New("Invalid decade, use the first year of the decade, e.g. 1990")
+		}
+		dates.narrowYears(req.Decade, 10)
+	}
+	filter.ReleasedFrom = dates.from
+	filter.ReleasedTo = dates.to
+
+	if req.HasLink != nil {
+		filter.HasLink = sql.NullBool{Bool: *req.HasLink, Valid: true}
+	}
+	if req.HasText != nil {
+		filter.HasText = sql.NullBool{Bool: *req.HasText, Valid: true}
+	}
+
+	return filter, nil
+}
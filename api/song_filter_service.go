@@ -3,66 +3,342 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
-	"time"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/par1ram/song-library/common"
+	"github.com/par1ram/song-library/internal/apierr"
 	"github.com/par1ram/song-library/internal/database"
 	"github.com/sirupsen/logrus"
 )
 
+// maxSongPageLimit is the largest page GET /songs and POST /songs/filter
+// serve; a larger limit is lowered to it.
+const maxSongPageLimit = 100
+
+// SongListResponse is a page of songs. Offset is set in offset mode, the
+// cursors in cursor mode; Total is left out when counting was turned off.
+type SongListResponse struct {
+	Items      []SongResponse `json:"items"`
+	Total      *int64         `json:"total,omitempty"`
+	Limit      int32          `json:"limit"`
+	Offset     *int32         `json:"offset,omitempty"`
+	NextCursor *string        `json:"next_cursor,omitempty"`
+	PrevCursor *string        `json:"prev_cursor,omitempty"`
+
+	hasMore bool
+}
+
+// GetSongWithFiltersAndPagination serves the deprecated POST /songs/filter
+// route, which takes the filters as a JSON body. Offset pages keep the old
+// bare array shape.
 func (cfg *ApiConfig) GetSongWithFiltersAndPagination(w http.ResponseWriter, r *http.Request) {
 	cfg.Logger.Info("GetSongWithFiltersAndPagination called")
 
-	var req struct {
-		Group       string `json:"group"`
-		Song        string `json:"song"`
-		ReleaseDate string `json:"release_date"`
-		Limit       int32  `json:"limit"`
-		Offset      int32  `json:"offset"`
-	}
+	var req SongFilterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		cfg.Logger.WithError(err).Error("Invalid request payload")
-		common.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
+		return
+	}
+
+	page, ok := cfg.listSongs(w, r, req, false)
+	if !ok {
+		return
+	}
+
+	if req.Cursor == nil {
+		common.RespondWithJSON(w, http.StatusOK, page.Items)
+		return
+	}
+	common.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// ListSongs serves GET /songs with the filters taken from query parameters.
+// Pass count=false to skip counting the matching songs on large tables.
+func (cfg *ApiConfig) ListSongs(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("ListSongs called")
+
+	req, err := songFilterFromQuery(r)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid query parameters")
+		apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
 		return
 	}
 
+	count, err := parseQueryBool(r, "count", true)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid count flag")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid count flag"))
+		return
+	}
+
+	page, ok := cfg.listSongs(w, r, req, count)
+	if !ok {
+		return
+	}
+
+	setLinkHeader(w, songPageLinks(r, page))
+	common.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// songPageLinks builds the RFC 8288 navigation links of a page from the
+// request URL, so the filters carry over.
+func songPageLinks(r *http.Request, page SongListResponse) []pageLink {
+	if page.Offset == nil {
+		links := []pageLink{{Rel: "first", URL: linkURL(r, url.Values{"cursor": {""}})}}
+		if page.PrevCursor != nil {
+			links = append(links, pageLink{Rel: "prev", URL: linkURL(r, url.Values{"cursor": {*page.PrevCursor}})})
+		}
+		if page.NextCursor != nil {
+			links = append(links, pageLink{Rel: "next", URL: linkURL(r, url.Values{"cursor": {*page.NextCursor}})})
+		}
+		return links
+	}
+
+	offsetURL := func(offset int64) string {
+		return linkURL(r, url.Values{"offset": {strconv.FormatInt(offset, 10)}})
+	}
+
+	// Offsets are computed in int64; a page beyond the int32 offsets the
+	// API accepts gets no link.
+	offset, limit := int64(*page.Offset), int64(page.Limit)
+	links := []pageLink{{Rel: "first", URL: offsetURL(0)}}
+	if offset > 0 {
+		links = append(links, pageLink{Rel: "prev", URL: offsetURL(max(offset-limit, 0))})
+	}
+	if page.hasMore && offset+limit <= math.MaxInt32 {
+		links = append(links, pageLink{Rel: "next", URL: offsetURL(offset + limit)})
+	}
+	if page.Total != nil && *page.Total > 0 {
+		if last := (*page.Total - 1) / limit * limit; last <= math.MaxInt32 {
+			links = append(links, pageLink{Rel: "last", URL: offsetURL(last)})
+		}
+	}
+	return links
+}
+
+// listSongs fetches a page of songs and, with count set, the number of
+// songs matching the filters. It responds itself on failure.
+func (cfg *ApiConfig) listSongs(w http.ResponseWriter, r *http.Request, req SongFilterRequest, count bool) (SongListResponse, bool) {
+	if req.Limit <= 0 {
+		cfg.Logger.Debug("Limit not provided or invalid, setting default to 10")
+		req.Limit = 10
+	}
+	if req.Limit > maxSongPageLimit {
+		cfg.Logger.WithField("limit", req.Limit).Debug("Limit too large, lowering it to the maximum")
+		req.Limit = maxSongPageLimit
+	}
+	if req.Offset < 0 {
+		cfg.Logger.Debug("Offset not provided or invalid, setting default to 0")
+		req.Offset = 0
+	}
+
+	filter, err := req.filter()
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid song filter")
+		apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+		return SongListResponse{}, false
+	}
+
+	sort, err := parseSongSort(req.Sort)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid sort")
+		apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+		return SongListResponse{}, false
+	}
+
+	fields, err := listSongFields(req.Fields, req.Include)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid fields")
+		apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+		return SongListResponse{}, false
+	}
+	params := database.ListSongsPageParams{
+		Filter:   filter,
+		Sort:     sort,
+		WithText: slices.Contains(fields, "text"),
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	}
+
 	cfg.Logger.WithFields(logrus.Fields{
-		"group":        req.Group,
-		"song":         req.Song,
-		"release_date": req.ReleaseDate,
-		"limit":        req.Limit,
-		"offset":       req.Offset,
-	}).Debug("Decoded request payload")
-
-	var releaseDate sql.NullTime
-	if req.ReleaseDate != "" {
-		parsedDate, err := time.Parse("2006-01-02", req.ReleaseDate)
+		"filter": filter,
+		"sort":   req.Sort,
+		"fields": fields,
+		"limit":  req.Limit,
+		"offset": req.Offset,
+	}).Debug("Listing songs with filters")
+
+	var (
+		page   SongListResponse
+		cursor songCursor
+	)
+	if req.Cursor != nil {
+		hasCursor := *req.Cursor != ""
+		if hasCursor {
+			if cursor, err = decodeSongCursor(*req.Cursor, database.SongSortKeys(sort)); err != nil {
+				cfg.Logger.WithError(err).Error("Invalid cursor")
+				apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+				return SongListResponse{}, false
+			}
+		}
+		page, err = cfg.songsByCursor(r, params, cursor, hasCursor)
+	} else {
+		page, err = cfg.songsByOffset(r, params)
+	}
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to fetch songs from database")
+		apierr.Write(w, r, apierr.NewInternal("Failed to fetch songs", err))
+		return SongListResponse{}, false
+	}
+	for i := range page.Items {
+		page.Items[i].fields = fields
+	}
+
+	if count {
+		total, err := cfg.DB.CountSongs(r.Context(), filter)
 		if err != nil {
-			cfg.Logger.WithError(err).Error("Invalid date format")
-			common.RespondWithError(w, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD")
-			return
+			cfg.Logger.WithError(err).Error("Failed to count songs")
+			apierr.Write(w, r, apierr.NewInternal("Failed to fetch songs", err))
+			return SongListResponse{}, false
 		}
-		releaseDate = sql.NullTime{Time: parsedDate, Valid: true}
-		cfg.Logger.Debug("Parsed release date successfully")
+		page.Total = &total
 	}
 
+	cfg.Logger.WithField("song_count", len(page.Items)).Info("Fetched songs successfully")
+	return page, true
+}
+
+// listSongFields resolves the attributes of list items: the requested
+// fields, or all but the lyrics unless include=text asks for them.
+func listSongFields(rawFields, include string) ([]string, error) {
+	fields, err := parseSongFields(rawFields)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = slices.DeleteFunc(slices.Clone(songFields), func(field string) bool {
+			return field == "text"
+		})
+	}
+
+	if include != "" {
+		for _, name := range strings.Split(include, ",") {
+			if strings.TrimSpace(name) != "text" {
+				return nil, errors.New("Unsupported include, use: text")
+			}
+		}
+		if !slices.Contains(fields, "text") {
+			fields = append(fields, "text")
+		}
+	}
+
+	return fields, nil
+}
+
+func newSongListItems(rows []database.ListSongsPageRow) []SongResponse {
+	items := make([]SongResponse, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, newSongResponseFromListRow(row))
+	}
+	return items
+}
+
+// songsByOffset fetches one extra row to find out whether there is a next
+// page, which keeps the links right when counting is turned off.
+func (cfg *ApiConfig) songsByOffset(r *http.Request, params database.ListSongsPageParams) (SongListResponse, error) {
+	limit, offset := params.Limit, params.Offset
+	params.Limit++
+
 	cfg.Logger.Debug("Querying database with filters")
-	songs, err := cfg.DB.GetSongWithFiltersAndPagination(r.Context(), database.GetSongWithFiltersAndPaginationParams{
-		Column1:     sql.NullString{String: req.Group, Valid: req.Group != ""},
-		Column2:     sql.NullString{String: req.Song, Valid: req.Song != ""},
-		ReleaseDate: releaseDate,
-		Limit:       req.Limit,
-		Offset:      req.Offset,
-	})
+	songs, err := cfg.DB.ListSongsPage(r.Context(), params)
 	if err != nil {
-		cfg.Logger.WithError(err).Error("Failed to fetch songs from database")
-		common.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch songs")
-		return
+		return SongListResponse{}, err
 	}
 
-	cfg.Logger.WithField("song_count", len(songs)).Info("Fetched songs successfully")
-	common.RespondWithJSON(w, http.StatusOK, songs)
+	page := SongListResponse{
+		Limit:   limit,
+		Offset:  &offset,
+		hasMore: len(songs) > int(limit),
+	}
+	if page.hasMore {
+		songs = songs[:limit]
+	}
+	page.Items = newSongListItems(songs)
+	return page, nil
+}
+
+// songsByCursor serves a keyset page. Like songsByOffset it fetches one
+// extra row to find out whether there is a page beyond the requested one.
+func (cfg *ApiConfig) songsByCursor(r *http.Request, params database.ListSongsPageParams, cursor songCursor, hasCursor bool) (SongListResponse, error) {
+	limit := params.Limit
+	params.Limit++
+	params.Offset = 0
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"cursor_key":    cursor.Key,
+		"cursor_before": cursor.Before,
+	}).Debug("Querying database with cursor")
+
+	if hasCursor {
+		if cursor.Before {
+			params.Before = cursor.key()
+		} else {
+			params.After = cursor.key()
+		}
+	}
+
+	songs, err := cfg.DB.ListSongsPage(r.Context(), params)
+	if err != nil {
+		return SongListResponse{}, err
+	}
+
+	hasMore := len(songs) > int(limit)
+	if hasMore {
+		if cursor.Before {
+			songs = songs[1:]
+		} else {
+			songs = songs[:limit]
+		}
+	}
+
+	// A page reached through a cursor always has a neighbour on the side
+	// it was reached from.
+	hasNext, hasPrev := hasMore, false
+	if hasCursor {
+		hasNext, hasPrev = true, true
+		if cursor.Before {
+			hasPrev = hasMore
+		} else {
+			hasNext = hasMore
+		}
+	}
+
+	page := SongListResponse{
+		Items:   newSongListItems(songs),
+		Limit:   limit,
+		hasMore: hasNext,
+	}
+	if len(songs) > 0 {
+		first, last := songs[0], songs[len(songs)-1]
+		keys := database.SongSortKeys(params.Sort)
+		if hasNext {
+			next := newSongCursor(formatSongSort(keys), last.Key(keys), false).encode()
+			page.NextCursor = &next
+		}
+		if hasPrev {
+			prev := newSongCursor(formatSongSort(keys), first.Key(keys), true).encode()
+			page.PrevCursor = &prev
+		}
+	}
+
+	return page, nil
 }
 
 type SongVersesRequest struct {
@@ -71,19 +347,59 @@ type SongVersesRequest struct {
 	Offset int32 `json:"offset,omitempty"`
 }
 
+type SongVersesResponse struct {
+	ID      int32    `json:"id"`
+	Verses  []string `json:"verses"`
+	Total   int64    `json:"total"`
+	Limit   int32    `json:"limit"`
+	Offset  int32    `json:"offset"`
+	HasMore bool     `json:"has_more"`
+}
+
+// GetSongVersesWithPagination serves the deprecated POST /songs/verses
+// route, which takes the song ID and window as a JSON body.
 func (cfg *ApiConfig) GetSongVersesWithPagination(w http.ResponseWriter, r *http.Request) {
 	cfg.Logger.Info("GetSongVersesWithPagination called")
 
 	var req SongVersesRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		cfg.Logger.WithError(err).Error("Invalid request payload")
-		common.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
+		return
+	}
+
+	cfg.songVerses(w, r, req)
+}
+
+// GetSongVerses serves GET /songs/{id}/verses.
+func (cfg *ApiConfig) GetSongVerses(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("GetSongVerses called")
+
+	var req SongVersesRequest
+	var err error
+	if req.ID, err = resourceID(r); err != nil {
+		cfg.Logger.WithError(err).Error("Invalid song ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid song ID"))
+		return
+	}
+	if req.Limit, err = parseQueryInt32(r, "limit", 0); err != nil {
+		cfg.Logger.WithError(err).Error("Invalid limit")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid limit"))
+		return
+	}
+	if req.Offset, err = parseQueryInt32(r, "offset", 0); err != nil {
+		cfg.Logger.WithError(err).Error("Invalid offset")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid offset"))
 		return
 	}
 
+	cfg.songVerses(w, r, req)
+}
+
+func (cfg *ApiConfig) songVerses(w http.ResponseWriter, r *http.Request, req SongVersesRequest) {
 	if req.ID <= 0 {
 		cfg.Logger.Error("Invalid song ID")
-		common.RespondWithError(w, http.StatusBadRequest, "Invalid song ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid song ID"))
 		return
 	}
 
@@ -105,29 +421,49 @@ func (cfg *ApiConfig) GetSongVersesWithPagination(w http.ResponseWriter, r *http
 		"offset":  offset,
 	}).Debug("Extracted query parameters")
 
+	total, err := cfg.DB.CountSongVerses(r.Context(), req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.Logger.WithField("song_id", req.ID).Warn("Song not found")
+			apierr.Write(w, r, apierr.NewNotFound("Song not found"))
+			return
+		}
+		cfg.Logger.WithError(err).Error("Failed to count song verses")
+		apierr.Write(w, r, apierr.NewInternal("Failed to fetch song verses", err))
+		return
+	}
+	if total == 0 {
+		cfg.Logger.WithField("song_id", req.ID).Warn("Song has no text")
+		apierr.Write(w, r, apierr.NewNotFound("Song has no text"))
+		return
+	}
+
 	cfg.Logger.Debug("Querying database for song verses")
-	dbVerses, err := cfg.DB.GetSongVersesWithPagination(r.Context(), database.GetSongVersesWithPaginationParams{
+	verses, err := cfg.DB.GetSongVersesWithPagination(r.Context(), database.GetSongVersesWithPaginationParams{
 		ID:     req.ID,
 		Limit:  limit,
 		Offset: offset,
 	})
 	if err != nil {
 		cfg.Logger.WithError(err).Error("Failed to fetch song verses from database")
-		common.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch song verses")
+		apierr.Write(w, r, apierr.NewInternal("Failed to fetch song verses", err))
 		return
 	}
-
-	dbVerse := dbVerses.(string)
-	verses := []string{dbVerse}
-	cfg.Logger.WithField("verse_count", len(verses)).Info("Fetched verses successfully")
-
-	result := struct {
-		ID     int32    `json:"id"`
-		Verses []string `json:"verses"`
-	}{
-		ID:     req.ID,
-		Verses: verses,
+	if verses == nil {
+		verses = []string{}
 	}
 
-	common.RespondWithJSON(w, http.StatusOK, result)
+	cfg.Logger.WithFields(logrus.Fields{
+		"verse_count": len(verses),
+		"total":       total,
+	}).Info("Fetched verses successfully")
+
+	common.RespondWithJSON(w, http.StatusOK, SongVersesResponse{
+		ID:      req.ID,
+		Verses:  verses,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: int64(offset)+int64(len(verses)) < total,
+	})
 }
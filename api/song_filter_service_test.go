@@ -0,0 +1,128 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSongPageLinksOffset(t *testing.T) {
+	int32Ptr := func(v int32) *int32 { return &v }
+	int64Ptr := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name string
+		page SongListResponse
+		want []pageLink
+	}{
+		{
+			name: "first page",
+			page: SongListResponse{Limit: 10, Offset: int32Ptr(0), Total: int64Ptr(25), hasMore: true},
+			want: []pageLink{
+				{"first", "/songs?group=Muse&offset=0"},
+				{"next", "/songs?group=Muse&offset=10"},
+				{"last", "/songs?group=Muse&offset=20"},
+			},
+		},
+		{
+			name: "middle page",
+			page: SongListResponse{Limit: 10, Offset: int32Ptr(10), Total: int64Ptr(25), hasMore: true},
+			want: []pageLink{
+				{"first", "/songs?group=Muse&offset=0"},
+				{"prev", "/songs?group=Muse&offset=0"},
+				{"next", "/songs?group=Muse&offset=20"},
+				{"last", "/songs?group=Muse&offset=20"},
+			},
+		},
+		{
+			name: "prev does not go below zero",
+			page: SongListResponse{Limit: 10, Offset: int32Ptr(5), Total: int64Ptr(12)},
+			want: []pageLink{
+				{"first", "/songs?group=Muse&offset=0"},
+				{"prev", "/songs?group=Muse&offset=0"},
+				{"last", "/songs?group=Muse&offset=10"},
+			},
+		},
+		{
+			name: "total a multiple of the limit",
+			page: SongListResponse{Limit: 10, Offset: int32Ptr(0), Total: int64Ptr(20), hasMore: true},
+			want: []pageLink{
+				{"first", "/songs?group=Muse&offset=0"},
+				{"next", "/songs?group=Muse&offset=10"},
+				{"last", "/songs?group=Muse&offset=10"},
+			},
+		},
+		{
+			name: "no songs",
+			page: SongListResponse{Limit: 10, Offset: int32Ptr(0), Total: int64Ptr(0)},
+			want: []pageLink{{"first", "/songs?group=Muse&offset=0"}},
+		},
+		{
+			name: "without a count",
+			page: SongListResponse{Limit: 10, Offset: int32Ptr(10), hasMore: true},
+			want: []pageLink{
+				{"first", "/songs?group=Muse&offset=0"},
+				{"prev", "/songs?group=Muse&offset=0"},
+				{"next", "/songs?group=Muse&offset=20"},
+			},
+		},
+		{
+			name: "next beyond int32",
+			page: SongListResponse{Limit: 100, Offset: int32Ptr(math.MaxInt32 - 50), hasMore: true},
+			want: []pageLink{
+				{"first", "/songs?group=Muse&offset=0"},
+				{"prev", "/songs?group=Muse&offset=2147483497"},
+			},
+		},
+		{
+			name: "last beyond int32",
+			page: SongListResponse{Limit: 100, Offset: int32Ptr(0), Total: int64Ptr(math.MaxInt32 + 200), hasMore: true},
+			want: []pageLink{
+				{"first", "/songs?group=Muse&offset=0"},
+				{"next", "/songs?group=Muse&offset=100"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/songs?group=Muse&offset=7", nil)
+			if got := songPageLinks(r, tt.page); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("songPageLinks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSongPageLinksCursor(t *testing.T) {
+	next, prev := "bmV4dA", "cHJldg"
+	r := httptest.NewRequest(http.MethodGet, "/songs?cursor=abc&sort=song_name", nil)
+
+	got := songPageLinks(r, SongListResponse{Limit: 10, NextCursor: &next, PrevCursor: &prev})
+	want := []pageLink{
+		{"first", "/songs?cursor=&sort=song_name"},
+		{"prev", "/songs?cursor=cHJldg&sort=song_name"},
+		{"next", "/songs?cursor=bmV4dA&sort=song_name"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("songPageLinks = %v, want %v", got, want)
+	}
+}
+
+func TestSetLinkHeader(t *testing.T) {
+	w := httptest.NewRecorder()
+	setLinkHeader(w, []pageLink{{"first", "/songs?offset=0"}, {"next", "/songs?offset=10"}})
+
+	want := `</songs?offset=0>; rel="first", </songs?offset=10>; rel="next"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Link = %q, want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	setLinkHeader(w, nil)
+	if _, ok := w.Header()["Link"]; ok {
+		t.Error("Link set without links")
+	}
+}
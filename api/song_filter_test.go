@@ -0,0 +1,253 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/par1ram/song-library/internal/database"
+)
+
+func TestSongFilterRequestFilter(t *testing.T) {
+	date := func(year int, month time.Month, day int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(year, month, day, 0, 0, 0, 0, time.UTC), Valid: true}
+	}
+	yes := true
+
+	tests := []struct {
+		name string
+		req  SongFilterRequest
+		want database.SongFilter
+	}{
+		{
+			name: "empty",
+			want: database.SongFilter{GroupMatch: database.MatchContains, SongMatch: database.MatchContains},
+		},
+		{
+			name: "names are trimmed and blank groups dropped",
+			req: SongFilterRequest{
+				Group:      " Muse ",
+				Groups:     []string{"Queen", "  "},
+				GroupMatch: "exact",
+				Song:       " Starlight ",
+				SongMatch:  "prefix",
+				Text:       " baby ",
+			},
+			want: database.SongFilter{
+				GroupNames: []string{"Muse", "Queen"},
+				GroupMatch: database.MatchExact,
+				SongName:   "Starlight",
+				SongMatch:  database.MatchPrefix,
+				Text:       "baby",
+			},
+		},
+		{
+			name: "release date is a single day",
+			req:  SongFilterRequest{ReleaseDate: "2006-07-16"},
+			want: database.SongFilter{
+				GroupMatch:   database.MatchContains,
+				SongMatch:    database.MatchContains,
+				ReleasedFrom: date(2006, time.July, 16),
+				ReleasedTo:   date(2006, time.July, 16),
+			},
+		},
+		{
+			name: "open range",
+			req:  SongFilterRequest{ReleaseFrom: "2000-01-01"},
+			want: database.SongFilter{
+				GroupMatch:   database.MatchContains,
+				SongMatch:    database.MatchContains,
+				ReleasedFrom: date(2000, time.January, 1),
+			},
+		},
+		{
+			name: "year",
+			req:  SongFilterRequest{Year: 1997},
+			want: database.SongFilter{
+				GroupMatch:   database.MatchContains,
+				SongMatch:    database.MatchContains,
+				ReleasedFrom: date(1997, time.January, 1),
+				ReleasedTo:   date(1997, time.December, 31),
+			},
+		},
+		{
+			name: "decade",
+			req:  SongFilterRequest{Decade: 1990},
+			want: database.SongFilter{
+				GroupMatch:   database.MatchContains,
+				SongMatch:    database.MatchContains,
+				ReleasedFrom: date(1990, time.January, 1),
+				ReleasedTo:   date(1999, time.December, 31),
+			},
+		},
+		{
+			name: "date filters narrow each other",
+			req:  SongFilterRequest{ReleaseFrom: "1995-06-01", ReleaseTo: "2010-01-01", Decade: 1990},
+			want: database.SongFilter{
+				GroupMatch:   database.MatchContains,
+				SongMatch:    database.MatchContains,
+				ReleasedFrom: date(1995, time.June, 1),
+				ReleasedTo:   date(1999, time.December, 31),
+			},
+		},
+		{
+			// The range is empty, which is not an error: no song matches.
+			name: "disjoint date filters",
+			req:  SongFilterRequest{Year: 1997, Decade: 2000},
+			want: database.SongFilter{
+				GroupMatch:   database.MatchContains,
+				SongMatch:    database.MatchContains,
+				ReleasedFrom: date(2000, time.January, 1),
+				ReleasedTo:   date(1997, time.December, 31),
+			},
+		},
+		{
+			name: "group ids and presence",
+			req:  SongFilterRequest{GroupIDs: []int32{1, 2}, HasLink: &yes, HasText: new(bool)},
+			want: database.SongFilter{
+				GroupIDs:   []int32{1, 2},
+				GroupMatch: database.MatchContains,
+				SongMatch:  database.MatchContains,
+				HasLink:    sql.NullBool{Bool: true, Valid: true},
+				HasText:    sql.NullBool{Valid: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.req.filter()
+			if err != nil {
+				t.Fatalf("filter: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSongFilterRequestFilterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  SongFilterRequest
+		want string
+	}{
+		{"match mode", SongFilterRequest{GroupMatch: "fuzzy"}, "Invalid group_match, use exact, prefix or contains"},
+		{"song match mode", SongFilterRequest{SongMatch: "EXACT"}, "Invalid song_match, use exact, prefix or contains"},
+		{"release date", SongFilterRequest{ReleaseDate: "16.07.2006"}, "Invalid release_date, use YYYY-MM-DD"},
+		{"impossible date", SongFilterRequest{ReleaseFrom: "2006-02-30"}, "Invalid release_from, use YYYY-MM-DD"},
+		{"release to", SongFilterRequest{ReleaseTo: "2006"}, "Invalid release_to, use YYYY-MM-DD"},
+		{"negative year", SongFilterRequest{Year: -1}, "Invalid year"},
+		{"five digit year", SongFilterRequest{Year: 10000}, "Invalid year"},
+		{"decade not on a boundary", SongFilterRequest{Decade: 1995}, "Invalid decade, use the first year of the decade, e.g. 1990"},
+		{"negative decade", SongFilterRequest{Decade: -10}, "Invalid decade, use the first year of the decade, e.g. 1990"},
+		{"decade past 9999", SongFilterRequest{Decade: 10000}, "Invalid decade, use the first year of the decade, e.g. 1990"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.req.filter()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("filter error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSongFilterFromQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet,
+		"/songs?group=Muse&group=Queen&group_id=1&group_id=2&song=Star&song_match=prefix&year=1997&has_link=false&limit=5&offset=10&cursor=", nil)
+
+	got, err := songFilterFromQuery(r)
+	if err != nil {
+		t.Fatalf("songFilterFromQuery: %v", err)
+	}
+	no, cursor := false, ""
+	want := SongFilterRequest{
+		Groups:    []string{"Muse", "Queen"},
+		GroupIDs:  []int32{1, 2},
+		Song:      "Star",
+		SongMatch: "prefix",
+		Year:      1997,
+		HasLink:   &no,
+		Limit:     5,
+		Offset:    10,
+		Cursor:    &cursor,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("songFilterFromQuery = %+v, want %+v", got, want)
+	}
+}
+
+func TestSongFilterFromQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"group_id=0", "Invalid group_id"},
+		{"group_id=abc", "Invalid group_id"},
+		{"year=nineties", "Invalid year"},
+		{"decade=1990s", "Invalid decade"},
+		{"has_text=maybe", "Invalid has_text flag"},
+		{"limit=ten", "Invalid limit"},
+		{"offset=3000000000", "Invalid offset"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/songs?"+tt.query, nil)
+		if _, err := songFilterFromQuery(r); err == nil || err.Error() != tt.want {
+			t.Errorf("songFilterFromQuery(%s) error = %v, want %q", tt.query, err, tt.want)
+		}
+	}
+}
+
+func TestParseSongSort(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []database.SongSort
+	}{
+		{"", []database.SongSort{{Field: "release_date", Desc: true}}},
+		{"  ", []database.SongSort{{Field: "release_date", Desc: true}}},
+		{"song_name", []database.SongSort{{Field: "song_name"}}},
+		{"-release_date, song_name", []database.SongSort{{Field: "release_date", Desc: true}, {Field: "song_name"}}},
+		{"group_name,-id", []database.SongSort{{Field: "group_name"}, {Field: "id", Desc: true}}},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSongSort(tt.raw)
+		if err != nil {
+			t.Errorf("parseSongSort(%q): %v", tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSongSort(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+		// Default aside, formatting gives back the sort without spaces.
+		if want := strings.ReplaceAll(tt.raw, " ", ""); want != "" && formatSongSort(got) != want {
+			t.Errorf("formatSongSort(%+v) = %q, want %q", got, formatSongSort(got), want)
+		}
+	}
+}
+
+func TestParseSongSortErrors(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"text", `Unknown sort field "text", allowed: song_name, group_name, release_date, id, created_at, updated_at`},
+		{"+song_name", `Unknown sort field "+song_name", allowed: song_name, group_name, release_date, id, created_at, updated_at`},
+		{"song_name,", `Unknown sort field "", allowed: song_name, group_name, release_date, id, created_at, updated_at`},
+		{"song_name,-song_name", `Duplicate sort field "song_name"`},
+	}
+
+	for _, tt := range tests {
+		if _, err := parseSongSort(tt.raw); err == nil || err.Error() != tt.want {
+			t.Errorf("parseSongSort(%q) error = %v, want %q", tt.raw, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/par1ram/song-library/common"
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+type SongResponse struct {
+	ID               int32          `json:"id"`
+	SongName         string         `json:"song_name"`
+	ReleaseDate      *string        `json:"release_date"`
+	Text             *string        `json:"text"`
+	Link             *string        `json:"link"`
+	GroupID          int32          `json:"group_id"`
+	GroupName        string         `json:"group_name"`
+	EnrichmentStatus string         `json:"enrichment_status"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Group            *groupResponse `json:"group,omitempty"`
+
+	// fields limits the JSON attributes to the listed ones when set.
+	fields []string
+}
+
+// songFields are the attributes of SongResponse that can be picked with
+// the fields parameter.
+var songFields = []string{"id", "song_name", "release_date", "text", "link", "group_id", "group_name", "enrichment_status", "created_at", "updated_at"}
+
+func newSongResponse(row database.GetSongByIDRow, includeGroup bool) SongResponse {
+	song := SongResponse{
+		ID:               row.ID,
+		SongName:         row.SongName,
+		ReleaseDate:      nullDateToPtr(row.ReleaseDate),
+		Text:             nullStringToPtr(row.Text),
+		Link:             nullStringToPtr(row.Link),
+		GroupID:          row.GroupID,
+		GroupName:        row.GroupName,
+		EnrichmentStatus: row.EnrichmentStatus,
+		CreatedAt:        row.CreatedAt,
+		UpdatedAt:        row.UpdatedAt,
+	}
+	if includeGroup {
+		song.Group = &groupResponse{
+			ID:        row.GroupID,
+			GroupName: row.GroupName,
+		}
+	}
+	return song
+}
+
+// newSongResponseFromListRow is newSongResponse for a listing row, which
+// never embeds the group.
+func newSongResponseFromListRow(row database.ListSongsPageRow) SongResponse {
+	return SongResponse{
+		ID:               row.ID,
+		SongName:         row.SongName,
+		ReleaseDate:      nullDateToPtr(row.ReleaseDate),
+		Text:             nullStringToPtr(row.Text),
+		Link:             nullStringToPtr(row.Link),
+		GroupID:          row.GroupID,
+		GroupName:        row.GroupName,
+		EnrichmentStatus: row.EnrichmentStatus,
+		CreatedAt:        row.CreatedAt,
+		UpdatedAt:        row.UpdatedAt,
+	}
+}
+
+func (s SongResponse) MarshalJSON() ([]byte, error) {
+	type plain SongResponse
+	data, err := json.Marshal(plain(s))
+	if err != nil || s.fields == nil {
+		return data, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	// Keep the attributes in the requested order.
+	var view bytes.Buffer
+	view.WriteByte('{')
+	for _, field := range s.fields {
+		value, ok := all[field]
+		if !ok {
+			continue
+		}
+		if view.Len() > 1 {
+			view.WriteByte(',')
+		}
+		name, _ := json.Marshal(field)
+		view.Write(name)
+		view.WriteByte(':')
+		view.Write(value)
+	}
+	view.WriteByte('}')
+	return view.Bytes(), nil
+}
+
+// parseSongFields reads a comma separated list of songFields. It returns
+// nil for an empty list.
+func parseSongFields(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(songFields, field) {
+			return nil, fmt.Errorf("Unknown field %q, allowed: %s", field, strings.Join(songFields, ", "))
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// GetSong serves GET /songs/{id}. include=group embeds the song's group,
+// fields picks the attributes to return.
+func (cfg *ApiConfig) GetSong(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("GetSong called")
+
+	songID, err := resourceID(r)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Received invalid song ID")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid song ID"))
+		return
+	}
+
+	fields, err := parseSongFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid fields")
+		apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+		return
+	}
+
+	var includeGroup bool
+	if include := r.URL.Query().Get("include"); include != "" {
+		for _, name := range strings.Split(include, ",") {
+			switch strings.TrimSpace(name) {
+			case "group":
+				includeGroup = true
+			default:
+				cfg.Logger.WithField("include", name).Error("Unsupported include")
+				apierr.Write(w, r, apierr.NewBadRequest("Unsupported include, use: group"))
+				return
+			}
+		}
+	}
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"song_id":       songID,
+		"include_group": includeGroup,
+	}).Debug("Querying database for song")
+
+	song, err := cfg.DB.GetSongByID(r.Context(), songID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.Logger.WithField("song_id", songID).Warn("Song not found")
+			apierr.Write(w, r, apierr.NewNotFound("Song not found"))
+			return
+		}
+		cfg.Logger.WithError(err).Error("Failed to fetch song from database")
+		apierr.Write(w, r, apierr.NewInternal("Failed to fetch song", err))
+		return
+	}
+
+	etag := songViewETag(song.Version, fields, includeGroup)
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagListed(header, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	response := newSongResponse(song, includeGroup)
+	if fields != nil {
+		response.fields = fields
+		if includeGroup {
+			response.fields = append(response.fields, "group")
+		}
+	}
+	common.RespondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,507 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/par1ram/song-library/common"
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/par1ram/song-library/internal/validate"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ImportCSV    = "csv"
+	ImportJSON   = "json"
+	ImportNDJSON = "ndjson"
+)
+
+const (
+	// maxImportIssues caps the rows listed in a report; the counts stay
+	// exact.
+	maxImportIssues = 1000
+	maxImportBytes  = 256 << 20
+	maxImportLine   = 1 << 20
+)
+
+// importFields are the song attributes an import row can set.
+var importFields = []string{"group", "song", "release_date", "text", "link"}
+
+// ImportOptions describe the source of an import. Columns maps song
+// attributes to the source columns (CSV) or keys (JSON) holding them;
+// unmapped attributes are read from the column of the same name.
+type ImportOptions struct {
+	Format  string
+	Columns map[string]string
+	// Enrich queues songs imported without any details for the external
+	// API.
+	Enrich bool
+}
+
+// ImportRow is one song of an import, by song attribute.
+type ImportRow struct {
+	GroupName   string `json:"group" validate:"required,max=255"`
+	SongName    string `json:"song" validate:"required,max=255"`
+	ReleaseDate string `json:"release_date" validate:"date"`
+	Text        string `json:"text" validate:"max=100000"`
+	Link        string `json:"link" validate:"url,max=2048"`
+}
+
+type ImportRowIssue struct {
+	Row    int32  `json:"row"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport counts the rows of an import. Rows are numbered from 1 in
+// source order, not counting a CSV header.
+type ImportReport struct {
+	Format        string           `json:"format"`
+	Rows          int              `json:"rows"`
+	Inserted      int64            `json:"inserted"`
+	Skipped       int              `json:"skipped"`
+	Rejected      int              `json:"rejected"`
+	GroupsCreated int64            `json:"groups_created"`
+	Enqueued      int64            `json:"enrichment_enqueued"`
+	Issues        []ImportRowIssue `json:"issues"`
+	// IssuesTruncated is set when more rows were skipped or rejected than
+	// listed in Issues.
+	IssuesTruncated bool `json:"issues_truncated,omitempty"`
+}
+
+func (report *ImportReport) addIssue(row int32, status, reason string) {
+	if len(report.Issues) >= maxImportIssues {
+		report.IssuesTruncated = true
+		return
+	}
+	report.Issues = append(report.Issues, ImportRowIssue{Row: row, Status: status, Reason: reason})
+}
+
+// errImportSource marks failures of the source itself, which abort the
+// import; invalid rows are only rejected.
+type errImportSource struct {
+	err error
+}
+
+func (e errImportSource) Error() string {
+	return e.err.Error()
+}
+
+func (e errImportSource) Unwrap() error {
+	return e.err
+}
+
+// IsImportSourceError reports whether err was caused by the import source
+// rather than the database.
+func IsImportSourceError(err error) bool {
+	return errors.As(err, &errImportSource{})
+}
+
+// ParseImportColumns reads a column mapping written as
+// "song=title,group=artist".
+func ParseImportColumns(raw string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, column, ok := strings.Cut(pair, "=")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, use field=column", pair)
+		}
+		if !slices.Contains(importFields, field) {
+			return nil, fmt.Errorf("unknown field %q, allowed: %s", field, strings.Join(importFields, ", "))
+		}
+		columns[field] = column
+	}
+	return columns, nil
+}
+
+// ImportFormat guesses the format from a file name or media type.
+func ImportFormat(name string) string {
+	if mediaType, _, err := mime.ParseMediaType(name); err == nil {
+		switch mediaType {
+		case "text/csv":
+			return ImportCSV
+		case "application/json":
+			return ImportJSON
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			return ImportNDJSON
+		}
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return ImportCSV
+	case ".json":
+		return ImportJSON
+	case ".ndjson", ".jsonl":
+		return ImportNDJSON
+	}
+	return ""
+}
+
+// RunImport streams songs from src into the database. The import runs in
+// one transaction: rows are validated as they are read, copied into a
+// staging table and moved to songs once the source is exhausted.
+func (cfg *ApiConfig) RunImport(ctx context.Context, src io.Reader, opts ImportOptions) (ImportReport, error) {
+	report := ImportReport{
+		Format: opts.Format,
+		Issues: []ImportRowIssue{},
+	}
+
+	read, err := importReader(opts.Format)
+	if err != nil {
+		return report, errImportSource{err}
+	}
+	column := func(field string) string {
+		if name, ok := opts.Columns[field]; ok {
+			return name
+		}
+		return field
+	}
+
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	copier, err := qtx.BeginImport(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	err = read(src, column, func(values map[string]string, readErr error) error {
+		report.Rows++
+		rowNumber := int32(report.Rows)
+		if readErr != nil {
+			report.Rejected++
+			report.addIssue(rowNumber, "rejected", readErr.Error())
+			return nil
+		}
+
+		row, reason := newImportRow(values)
+		if reason != "" {
+			report.Rejected++
+			report.addIssue(rowNumber, "rejected", reason)
+			return nil
+		}
+
+		var releaseDate sql.NullTime
+		if row.ReleaseDate != "" {
+			parsedDate, _ := time.Parse(validate.DateLayout, row.ReleaseDate)
+			releaseDate = sql.NullTime{Time: parsedDate, Valid: true}
+		}
+		return copier.Add(ctx, database.ImportSongRow{
+			RowNumber:   rowNumber,
+			GroupName:   row.GroupName,
+			SongName:    row.SongName,
+			ReleaseDate: releaseDate,
+			Text:        sql.NullString{String: row.Text, Valid: row.Text != ""},
+			Link:        sql.NullString{String: row.Link, Valid: row.Link != ""},
+		})
+	})
+	if err != nil {
+		return report, err
+	}
+	if err := copier.Close(ctx); err != nil {
+		return report, err
+	}
+
+	result, err := qtx.FinishImport(ctx, opts.Enrich)
+	if err != nil {
+		return report, err
+	}
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+
+	report.Inserted = result.Inserted
+	report.GroupsCreated = result.GroupsCreated
+	report.Enqueued = result.Enqueued
+	report.Skipped = len(result.Skipped)
+	for _, skip := range result.Skipped {
+		report.addIssue(skip.RowNumber, "skipped", skip.Reason)
+	}
+	slices.SortStableFunc(report.Issues, func(a, b ImportRowIssue) int {
+		return int(a.Row - b.Row)
+	})
+	return report, nil
+}
+
+// newImportRow validates the values of a row by song attribute. The
+// reason is empty for a valid row.
+func newImportRow(values map[string]string) (ImportRow, string) {
+	row := ImportRow{
+		GroupName:   strings.TrimSpace(values["group"]),
+		SongName:    strings.TrimSpace(values["song"]),
+		ReleaseDate: strings.TrimSpace(values["release_date"]),
+		Text:        values["text"],
+		Link:        strings.TrimSpace(values["link"]),
+	}
+
+	errs := validate.Struct(row)
+	if len(errs) == 0 {
+		return row, ""
+	}
+	reasons := make([]string, 0, len(errs))
+	for _, fieldErr := range errs {
+		reasons = append(reasons, fieldErr.Field+" "+fieldErr.Message)
+	}
+	return row, strings.Join(reasons, "; ")
+}
+
+// importRowFunc receives the values of each source row by song attribute,
+// or the reason the row could not be read.
+type importRowFunc func(values map[string]string, err error) error
+
+type importReadFunc func(src io.Reader, column func(field string) string, emit importRowFunc) error
+
+func importReader(format string) (importReadFunc, error) {
+	switch format {
+	case ImportCSV:
+		return readImportCSV, nil
+	case ImportJSON:
+		return readImportJSON, nil
+	case ImportNDJSON:
+		return readImportNDJSON, nil
+	}
+	return nil, fmt.Errorf("unknown import format %q, use csv, json or ndjson", format)
+}
+
+func readImportCSV(src io.Reader, column func(field string) string, emit importRowFunc) error {
+	reader := csv.NewReader(src)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return errImportSource{fmt.Errorf("failed to read the CSV header: %w", err)}
+	}
+	positions := make(map[string]int)
+	for _, field := range importFields {
+		i := slices.Index(header, column(field))
+		if i < 0 {
+			if field == "group" || field == "song" {
+				return errImportSource{fmt.Errorf("column %q for %s not found in the CSV header", column(field), field)}
+			}
+			continue
+		}
+		positions[field] = i
+	}
+
+	// A quoted field that spans lines and is never closed takes the rest of
+	// the file with it. Whether it did is only known once the reader hits
+	// the end, so its rejection waits for the next read.
+	var openQuote *csv.ParseError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			if openQuote != nil {
+				return errImportSource{fmt.Errorf("quoted field opened on line %d is never closed, lines %d-%d could not be read",
+					openQuote.StartLine, openQuote.StartLine, openQuote.Line)}
+			}
+			return nil
+		}
+		if openQuote != nil {
+			if err := emit(nil, fmt.Errorf("invalid CSV on line %d: %w", openQuote.Line, openQuote.Err)); err != nil {
+				return err
+			}
+			openQuote = nil
+		}
+		// A malformed row only rejects that row; the reader resumes on the
+		// next line.
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if errors.Is(parseErr.Err, csv.ErrQuote) && parseErr.StartLine < parseErr.Line {
+				openQuote = parseErr
+				continue
+			}
+			if err := emit(nil, fmt.Errorf("invalid CSV on line %d: %w", parseErr.Line, parseErr.Err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return errImportSource{err}
+		}
+		if len(record) != len(header) {
+			if err := emit(nil, fmt.Errorf("expected %d columns, got %d", len(header), len(record))); err != nil {
+				return err
+			}
+			continue
+		}
+
+		values := make(map[string]string, len(positions))
+		for field, i := range positions {
+			values[field] = record[i]
+		}
+		if err := emit(values, nil); err != nil {
+			return err
+		}
+	}
+}
+
+func readImportJSON(src io.Reader, column func(field string) string, emit importRowFunc) error {
+	decoder := json.NewDecoder(src)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return errImportSource{errors.New("a JSON import must be an array of objects")}
+	}
+
+	for decoder.More() {
+		var object map[string]json.RawMessage
+		if err := decoder.Decode(&object); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				return errImportSource{fmt.Errorf("invalid JSON: %w", err)}
+			}
+			if err := emit(nil, errors.New("not a JSON object")); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := emit(importValues(object, column)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return errImportSource{fmt.Errorf("invalid JSON: %w", err)}
+	}
+	return nil
+}
+
+func readImportNDJSON(src io.Reader, column func(field string) string, emit importRowFunc) error {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxImportLine)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(line, &object); err != nil || object == nil {
+			if err := emit(nil, errors.New("not a JSON object")); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := emit(importValues(object, column)); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errImportSource{err}
+	}
+	return nil
+}
+
+// importValues picks the song attributes of a JSON object. Strings are
+// taken as they are, other scalars in their JSON spelling.
+func importValues(object map[string]json.RawMessage, column func(field string) string) (map[string]string, error) {
+	values := make(map[string]string, len(importFields))
+	for _, field := range importFields {
+		raw, ok := object[column(field)]
+		if !ok {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		switch v := value.(type) {
+		case nil:
+		case string:
+			values[field] = v
+		case map[string]any, []any:
+			return nil, fmt.Errorf("%s must be a string", field)
+		default:
+			values[field] = string(raw)
+		}
+	}
+	return values, nil
+}
+
+// ImportSongs loads a CSV, JSON or NDJSON catalogue from the request body.
+// The format comes from the format parameter or the Content-Type.
+func (cfg *ApiConfig) ImportSongs(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("ImportSongs called")
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = ImportFormat(r.Header.Get("Content-Type"))
+	}
+	if _, err := importReader(format); err != nil {
+		cfg.Logger.WithError(err).Error("Invalid import format")
+		apierr.Write(w, r, apierr.NewBadRequest("Unknown import format, pass format=csv|json|ndjson or a matching Content-Type"))
+		return
+	}
+
+	columns, err := ParseImportColumns(query.Get("columns"))
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid column mapping")
+		apierr.Write(w, r, apierr.NewValidation(apierr.FieldError{Field: "columns", Message: err.Error()}))
+		return
+	}
+	enrich, err := parseQueryBool(r, "enrich", false)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Invalid enrich flag")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid enrich flag"))
+		return
+	}
+
+	// Large catalogues take longer than the server's timeouts allow.
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		cfg.Logger.WithError(err).Warn("Failed to lift read deadline")
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		cfg.Logger.WithError(err).Warn("Failed to lift write deadline")
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	report, err := cfg.RunImport(r.Context(), body, ImportOptions{
+		Format:  format,
+		Columns: columns,
+		Enrich:  enrich,
+	})
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to import songs")
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			apierr.Write(w, r, apierr.NewBadRequest(fmt.Sprintf("Import is larger than %d bytes", maxErr.Limit)))
+		case IsImportSourceError(err):
+			apierr.Write(w, r, apierr.NewBadRequest(err.Error()))
+		default:
+			apierr.Write(w, r, apierr.NewInternal("Failed to import songs", err))
+		}
+		return
+	}
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"rows":     report.Rows,
+		"inserted": report.Inserted,
+		"skipped":  report.Skipped,
+		"rejected": report.Rejected,
+	}).Info("Songs imported")
+	common.RespondWithJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// importedRow is what a reader emitted for one source row: its values, or
+// why it could not be read.
+type importedRow struct {
+	values map[string]string
+	err    string
+}
+
+func readImport(t *testing.T, read importReadFunc, src string, columns map[string]string) ([]importedRow, error) {
+	t.Helper()
+	column := func(field string) string {
+		if name, ok := columns[field]; ok {
+			return name
+		}
+		return field
+	}
+	var rows []importedRow
+	err := read(strings.NewReader(src), column, func(values map[string]string, err error) error {
+		row := importedRow{values: values}
+		if err != nil {
+			row.err = err.Error()
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+func TestReadImportCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		columns map[string]string
+		want    []importedRow
+	}{
+		{
+			name: "rows",
+			src:  "group,song,release_date\nMuse,Starlight,2006-07-16\nQueen,\"Bohemian Rhapsody\",\n",
+			want: []importedRow{
+				{values: map[string]string{"group": "Muse", "song": "Starlight", "release_date": "2006-07-16"}},
+				{values: map[string]string{"group": "Queen", "song": "Bohemian Rhapsody", "release_date": ""}},
+			},
+		},
+		{
+			name:    "mapped columns in any order",
+			src:     "title,artist,extra\nStarlight,Muse,x\n",
+			columns: map[string]string{"group": "artist", "song": "title"},
+			want: []importedRow{
+				{values: map[string]string{"group": "Muse", "song": "Starlight"}},
+			},
+		},
+		{
+			name: "quoted field over several lines",
+			src:  "group,song,text\nMuse,Starlight,\"Far away\nThis ship is taking me\"\n",
+			want: []importedRow{
+				{values: map[string]string{"group": "Muse", "song": "Starlight", "text": "Far away\nThis ship is taking me"}},
+			},
+		},
+		{
+			name: "wrong column count",
+			src:  "group,song\nMuse\nQueen,Innuendo,1991\nMuse,Uprising\n",
+			want: []importedRow{
+				{err: "expected 2 columns, got 1"},
+				{err: "expected 2 columns, got 3"},
+				{values: map[string]string{"group": "Muse", "song": "Uprising"}},
+			},
+		},
+		{
+			name: "bare quote rejects only its row",
+			src:  "group,song\nMuse,Star\"light\nQueen,Innuendo\n",
+			want: []importedRow{
+				{err: `invalid CSV on line 2: bare " in non-quoted-field`},
+				{values: map[string]string{"group": "Queen", "song": "Innuendo"}},
+			},
+		},
+		{
+			name: "text after a closing quote rejects only its row",
+			src:  "group,song\nMuse,\"Star\"light\nQueen,Innuendo\n",
+			want: []importedRow{
+				{err: `invalid CSV on line 2: extraneous or missing " in quoted-field`},
+				{values: map[string]string{"group": "Queen", "song": "Innuendo"}},
+			},
+		},
+		{
+			name: "unclosed quote on the last line",
+			src:  "group,song\nQueen,Innuendo\nMuse,\"Starlight",
+			want: []importedRow{
+				{values: map[string]string{"group": "Queen", "song": "Innuendo"}},
+				{err: `invalid CSV on line 3: extraneous or missing " in quoted-field`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readImport(t, readImportCSV, tt.src, tt.columns)
+			if err != nil {
+				t.Fatalf("readImportCSV: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadImportCSVSourceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty", "", "failed to read the CSV header: EOF"},
+		{"missing song column", "group,title\nMuse,Starlight\n", `column "song" for song not found in the CSV header`},
+		{
+			// The reader takes everything after the quote as one field, so
+			// the rows after it would otherwise vanish as a single rejection.
+			name: "quote never closed",
+			src:  "group,song\nQueen,Innuendo\nMuse,\"Starlight\nQueen,Bicycle Race\nMuse,Uprising\n",
+			want: "quoted field opened on line 3 is never closed, lines 3-5 could not be read",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readImport(t, readImportCSV, tt.src, nil)
+			if !IsImportSourceError(err) || err.Error() != tt.want {
+				t.Errorf("readImportCSV error = %v, want source error %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadImportJSON(t *testing.T) {
+	src := `[
+		{"group": "Muse", "song": "Starlight", "release_date": "2006-07-16"},
+		"Queen",
+		{"group": "Queen", "song": ["Innuendo"]},
+		{"group": "Muse", "song": 1984, "link": null}
+	]`
+
+	got, err := readImport(t, readImportJSON, src, nil)
+	if err != nil {
+		t.Fatalf("readImportJSON: %v", err)
+	}
+	want := []importedRow{
+		{values: map[string]string{"group": "Muse", "song": "Starlight", "release_date": "2006-07-16"}},
+		{err: "not a JSON object"},
+		{err: "song must be a string"},
+		{values: map[string]string{"group": "Muse", "song": "1984"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadImportJSONSourceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"object", `{"group": "Muse", "song": "Starlight"}`},
+		{"not JSON", `group,song`},
+		{"truncated", `[{"group": "Muse", "song": "Starlight"}`},
+		{"broken element", `[{"group": "Muse", "song": }]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readImport(t, readImportJSON, tt.src, nil); !IsImportSourceError(err) {
+				t.Errorf("readImportJSON error = %v, want a source error", err)
+			}
+		})
+	}
+}
+
+func TestReadImportNDJSON(t *testing.T) {
+	src := "{\"group\": \"Muse\", \"song\": \"Starlight\"}\n\n" +
+		"{\"group\": \"Muse\", \"song\": }\n" +
+		"[\"Queen\", \"Innuendo\"]\n" +
+		"null\n" +
+		"  {\"artist\": \"Queen\", \"song\": \"Innuendo\"}  \n"
+
+	got, err := readImport(t, readImportNDJSON, src, map[string]string{"group": "artist"})
+	if err != nil {
+		t.Fatalf("readImportNDJSON: %v", err)
+	}
+	want := []importedRow{
+		{values: map[string]string{"song": "Starlight"}},
+		{err: "not a JSON object"},
+		{err: "not a JSON object"},
+		{err: "not a JSON object"},
+		{values: map[string]string{"group": "Queen", "song": "Innuendo"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %+v, want %+v", got, want)
+	}
+}
+
+func TestImportValues(t *testing.T) {
+	identity := func(field string) string { return field }
+
+	tests := []struct {
+		name    string
+		object  string
+		want    map[string]string
+		wantErr string
+	}{
+		{"strings", `{"group": "Muse", "song": " Starlight "}`, map[string]string{"group": "Muse", "song": " Starlight "}, ""},
+		{"other scalars as spelled", `{"song": 1.50, "text": true}`, map[string]string{"song": "1.50", "text": "true"}, ""},
+		{"null is absent", `{"group": "Muse", "link": null}`, map[string]string{"group": "Muse"}, ""},
+		{"unknown keys ignored", `{"group": "Muse", "album": {"name": "Black Holes"}}`, map[string]string{"group": "Muse"}, ""},
+		{"object", `{"group": {"name": "Muse"}}`, nil, "group must be a string"},
+		{"array", `{"text": ["Far away"]}`, nil, "text must be a string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.object), &object); err != nil {
+				t.Fatal(err)
+			}
+			got, err := importValues(object, identity)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("importValues error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("importValues: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("importValues = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewImportRow(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     map[string]string
+		want       ImportRow
+		wantReason string
+	}{
+		{
+			name:   "trimmed except the text",
+			values: map[string]string{"group": " Muse ", "song": "Starlight ", "release_date": " 2006-07-16", "text": " Far away ", "link": " https://example.com "},
+			want:   ImportRow{GroupName: "Muse", SongName: "Starlight", ReleaseDate: "2006-07-16", Text: " Far away ", Link: "https://example.com"},
+		},
+		{
+			name:       "blank names",
+			values:     map[string]string{"group": " ", "release_date": "16.07.2006", "link": "example"},
+			want:       ImportRow{ReleaseDate: "16.07.2006", Link: "example"},
+			wantReason: "group is required; song is required; release_date must be a date in YYYY-MM-DD format; link must be an absolute http or https URL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := newImportRow(tt.values)
+			if got != tt.want || reason != tt.wantReason {
+				t.Errorf("newImportRow = %+v, %q, want %+v, %q", got, reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/par1ram/song-library/internal/database"
+)
+
+// SongInfoCacheEntry is a cached answer of the external API. Entries with
+// Found unset remember that the song is unknown (negative caching).
+type SongInfoCacheEntry struct {
+	Found     bool
+	Details   SongDetails
+	ExpiresAt time.Time
+}
+
+type SongInfoCache interface {
+	// Get reports ok=false for missing and expired entries.
+	Get(ctx context.Context, key string) (entry SongInfoCacheEntry, ok bool, err error)
+	Set(ctx context.Context, key string, entry SongInfoCacheEntry) error
+}
+
+type SongInfoCacheStats struct {
+	Backend string `json:"backend"`
+	Hits    int64  `json:"hits"`
+	Misses  int64  `json:"misses"`
+	Errors  int64  `json:"errors"`
+}
+
+type songInfoCacheBypassKey struct{}
+
+// withSongInfoCacheBypass makes CachingSongInfoProvider skip the lookup and
+// go to the upstream, refreshing the cached entry with the answer.
+func withSongInfoCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, songInfoCacheBypassKey{}, true)
+}
+
+// CachingSongInfoProvider answers repeated lookups of the same normalized
+// group/song pair from a cache. Found songs are kept for TTL, songs the
+// upstream does not know for NegativeTTL. Cache failures are counted and
+// otherwise ignored, the upstream is asked instead.
+type CachingSongInfoProvider struct {
+	Provider    SongInfoProvider
+	Cache       SongInfoCache
+	Backend     string
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	now    clock
+	hits   atomic.Int64
+	misses atomic.Int64
+	errors atomic.Int64
+}
+
+func (p *CachingSongInfoProvider) FetchSongInfo(ctx context.Context, group, song string) (SongDetails, error) {
+	key := songInfoKey(group, song)
+
+	if bypass, _ := ctx.Value(songInfoCacheBypassKey{}).(bool); !bypass {
+		entry, ok, err := p.Cache.Get(ctx, key)
+		if err != nil {
+			p.errors.Add(1)
+		}
+		if ok {
+			p.hits.Add(1)
+			if !entry.Found {
+				return SongDetails{}, ErrSongInfoNotFound
+			}
+			return entry.Details, nil
+		}
+		p.misses.Add(1)
+	}
+
+	details, err := p.Provider.FetchSongInfo(ctx, group, song)
+	switch {
+	case err == nil:
+		p.store(ctx, key, SongInfoCacheEntry{
+			Found:     true,
+			Details:   details,
+			ExpiresAt: p.now.Now().Add(p.TTL),
+		})
+	case errors.Is(err, ErrSongInfoNotFound) && p.NegativeTTL > 0:
+		p.store(ctx, key, SongInfoCacheEntry{
+			ExpiresAt: p.now.Now().Add(p.NegativeTTL),
+		})
+	}
+
+	return details, err
+}
+
+func (p *CachingSongInfoProvider) store(ctx context.Context, key string, entry SongInfoCacheEntry) {
+	if err := p.Cache.Set(ctx, key, entry); err != nil {
+		p.errors.Add(1)
+	}
+}
+
+func (p *CachingSongInfoProvider) Stats() SongInfoCacheStats {
+	return SongInfoCacheStats{
+		Backend: p.Backend,
+		Hits:    p.hits.Load(),
+		Misses:  p.misses.Load(),
+		Errors:  p.errors.Load(),
+	}
+}
+
+// LRUSongInfoCache keeps at most Size entries in memory, evicting the
+// least recently used one first.
+type LRUSongInfoCache struct {
+	size int
+	now  clock
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruSongInfoItem struct {
+	key   string
+	entry SongInfoCacheEntry
+}
+
+func NewLRUSongInfoCache(size int) *LRUSongInfoCache {
+	return &LRUSongInfoCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUSongInfoCache) Get(ctx context.Context, key string) (SongInfoCacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return SongInfoCacheEntry{}, false, nil
+	}
+
+	item := elem.Value.(*lruSongInfoItem)
+	if c.now.Now().After(item.entry.ExpiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return SongInfoCacheEntry{}, false, nil
+	}
+
+	c.order.MoveToFront(elem)
+	return item.entry, true, nil
+}
+
+func (c *LRUSongInfoCache) Set(ctx context.Context, key string, entry SongInfoCacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruSongInfoItem).entry = entry
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&lruSongInfoItem{key: key, entry: entry})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruSongInfoItem).key)
+	}
+
+	return nil
+}
+
+// PostgresSongInfoCache stores entries in the song_info_cache table, so
+// they survive restarts and are shared between instances.
+type PostgresSongInfoCache struct {
+	DB *database.Queries
+}
+
+func (c *PostgresSongInfoCache) Get(ctx context.Context, key string) (SongInfoCacheEntry, bool, error) {
+	row, err := c.DB.GetSongInfoCacheEntry(ctx, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SongInfoCacheEntry{}, false, nil
+		}
+		return SongInfoCacheEntry{}, false, err
+	}
+
+	return SongInfoCacheEntry{
+		Found: row.Found,
+		Details: SongDetails{
+			ReleaseDate: row.ReleaseDate,
+			Text:        row.Text,
+			Link:        row.Link,
+		},
+		ExpiresAt: row.ExpiresAt,
+	}, true, nil
+}
+
+func (c *PostgresSongInfoCache) Set(ctx context.Context, key string, entry SongInfoCacheEntry) error {
+	return c.DB.UpsertSongInfoCacheEntry(ctx, database.UpsertSongInfoCacheEntryParams{
+		CacheKey:    key,
+		Found:       entry.Found,
+		ReleaseDate: entry.Details.ReleaseDate,
+		Text:        entry.Details.Text,
+		Link:        entry.Details.Link,
+		ExpiresAt:   entry.ExpiresAt,
+	})
+}
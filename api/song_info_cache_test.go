@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRUSongInfoCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUSongInfoCache(2)
+	entry := func(text string) SongInfoCacheEntry {
+		return SongInfoCacheEntry{Found: true, Details: SongDetails{Text: text}, ExpiresAt: time.Now().Add(time.Hour)}
+	}
+
+	cache.Set(ctx, "a", entry("a"))
+	cache.Set(ctx, "b", entry("b"))
+	// Reading a makes b the least recently used entry.
+	if _, ok, _ := cache.Get(ctx, "a"); !ok {
+		t.Fatal("a missing before eviction")
+	}
+	cache.Set(ctx, "c", entry("c"))
+
+	if _, ok, _ := cache.Get(ctx, "b"); ok {
+		t.Error("b survived, want it evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if got, ok, _ := cache.Get(ctx, key); !ok || got.Details.Text != key {
+			t.Errorf("Get(%s) = %+v, %v, want the stored entry", key, got, ok)
+		}
+	}
+
+	// Overwriting an entry neither grows the cache nor evicts.
+	cache.Set(ctx, "a", entry("a2"))
+	if got, _, _ := cache.Get(ctx, "a"); got.Details.Text != "a2" {
+		t.Errorf("Get(a) = %+v, want the overwritten entry", got)
+	}
+	if _, ok, _ := cache.Get(ctx, "c"); !ok {
+		t.Error("c evicted by an overwrite")
+	}
+}
+
+func TestLRUSongInfoCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	clk := newFakeClock()
+	cache := NewLRUSongInfoCache(10)
+	cache.now = clk.Now
+
+	cache.Set(ctx, "a", SongInfoCacheEntry{Found: true, ExpiresAt: clk.Now().Add(time.Minute)})
+
+	clk.Advance(time.Minute)
+	if _, ok, _ := cache.Get(ctx, "a"); !ok {
+		t.Fatal("entry expired at its expiry time, want it kept until then")
+	}
+	clk.Advance(time.Nanosecond)
+	if _, ok, _ := cache.Get(ctx, "a"); ok {
+		t.Fatal("entry served after its expiry time")
+	}
+	if cache.order.Len() != 0 || len(cache.entries) != 0 {
+		t.Error("expired entry was not dropped")
+	}
+}
+
+// failingSongInfoCache fails every call.
+type failingSongInfoCache struct{}
+
+func (failingSongInfoCache) Get(ctx context.Context, key string) (SongInfoCacheEntry, bool, error) {
+	return SongInfoCacheEntry{}, false, errors.New("cache down")
+}
+
+func (failingSongInfoCache) Set(ctx context.Context, key string, entry SongInfoCacheEntry) error {
+	return errors.New("cache down")
+}
+
+func TestCachingSongInfoProvider(t *testing.T) {
+	ctx := context.Background()
+	details := SongDetails{ReleaseDate: "16.07.2006", Text: "Ooh baby"}
+	clk := newFakeClock()
+	upstream := &fakeSongInfoProvider{fetch: answers(details)}
+	cache := NewLRUSongInfoCache(10)
+	cache.now = clk.Now
+	p := &CachingSongInfoProvider{Provider: upstream, Cache: cache, TTL: time.Hour}
+	p.now = clk.Now
+
+	fetch := func(ctx context.Context, group, song string, wantCalls int) {
+		t.Helper()
+		got, err := p.FetchSongInfo(ctx, group, song)
+		if err != nil || got != details {
+			t.Fatalf("FetchSongInfo = %+v, %v, want %+v", got, err, details)
+		}
+		if upstream.Calls() != wantCalls {
+			t.Fatalf("upstream calls = %d, want %d", upstream.Calls(), wantCalls)
+		}
+	}
+
+	fetch(ctx, "Muse", "Supermassive Black Hole", 1)
+	// The key ignores case and extra whitespace.
+	fetch(ctx, " muse ", "supermassive  black hole", 1)
+
+	// A bypass goes to the upstream even on a hit.
+	fetch(withSongInfoCacheBypass(ctx), "Muse", "Supermassive Black Hole", 2)
+
+	clk.Advance(time.Hour + time.Second)
+	fetch(ctx, "Muse", "Supermassive Black Hole", 3)
+
+	if got, want := p.Stats(), (SongInfoCacheStats{Hits: 1, Misses: 2}); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestCachingSongInfoProviderBypassRefreshes(t *testing.T) {
+	ctx := context.Background()
+	upstream := &fakeSongInfoProvider{fetch: func(ctx context.Context, call int) (SongDetails, error) {
+		return SongDetails{Text: []string{"old", "new"}[min(call, 1)]}, nil
+	}}
+	p := &CachingSongInfoProvider{Provider: upstream, Cache: NewLRUSongInfoCache(10), TTL: time.Hour}
+
+	p.FetchSongInfo(ctx, "Muse", "Starlight")
+	p.FetchSongInfo(withSongInfoCacheBypass(ctx), "Muse", "Starlight")
+
+	got, err := p.FetchSongInfo(ctx, "Muse", "Starlight")
+	if err != nil || got.Text != "new" {
+		t.Errorf("FetchSongInfo after a bypass = %+v, %v, want the refreshed entry", got, err)
+	}
+	if upstream.Calls() != 2 {
+		t.Errorf("upstream calls = %d, want 2", upstream.Calls())
+	}
+}
+
+func TestCachingSongInfoProviderNegative(t *testing.T) {
+	ctx := context.Background()
+	upstreamErr := errors.New("connection refused")
+
+	tests := []struct {
+		name        string
+		err         error
+		negativeTTL time.Duration
+		wantCalls   int
+	}{
+		{name: "unknown song is remembered", err: ErrSongInfoNotFound, negativeTTL: time.Minute, wantCalls: 1},
+		{name: "negative caching off", err: ErrSongInfoNotFound, wantCalls: 2},
+		{name: "errors are never cached", err: upstreamErr, negativeTTL: time.Minute, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &fakeSongInfoProvider{fetch: func(ctx context.Context, call int) (SongDetails, error) {
+				return SongDetails{}, tt.err
+			}}
+			p := &CachingSongInfoProvider{
+				Provider:    upstream,
+				Cache:       NewLRUSongInfoCache(10),
+				TTL:         time.Hour,
+				NegativeTTL: tt.negativeTTL,
+			}
+
+			for range 2 {
+				if _, err := p.FetchSongInfo(ctx, "Muse", "Unknown"); !errors.Is(err, tt.err) {
+					t.Fatalf("FetchSongInfo error = %v, want %v", err, tt.err)
+				}
+			}
+			if upstream.Calls() != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", upstream.Calls(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachingSongInfoProviderCacheFailure(t *testing.T) {
+	details := SongDetails{Text: "Ooh baby"}
+	upstream := &fakeSongInfoProvider{fetch: answers(details)}
+	p := &CachingSongInfoProvider{Provider: upstream, Cache: failingSongInfoCache{}, TTL: time.Hour}
+
+	got, err := p.FetchSongInfo(context.Background(), "Muse", "Starlight")
+	if err != nil || got != details {
+		t.Fatalf("FetchSongInfo = %+v, %v, want the upstream answer", got, err)
+	}
+	// Both the lookup and the store failed.
+	if stats := p.Stats(); stats.Errors != 2 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 2 errors and 1 miss", stats)
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// SongDetails is what the external song info API knows about a song.
+type SongDetails struct {
+	ReleaseDate string `json:"releaseDate"`
+	Text        string `json:"text"`
+	Link        string `json:"link"`
+}
+
+var ErrSongInfoNotFound = errors.New("song info not found")
+
+// SongInfoProvider looks up song details by group and song name. It returns
+// ErrSongInfoNotFound when the source does not know the song.
+type SongInfoProvider interface {
+	FetchSongInfo(ctx context.Context, group, song string) (SongDetails, error)
+}
+
+// SongInfoStatusError is returned when the upstream answers with a status
+// other than 200 or 404.
+type SongInfoStatusError struct {
+	StatusCode int
+}
+
+func (e *SongInfoStatusError) Error() string {
+	return fmt.Sprintf("song info API returned status %d", e.StatusCode)
+}
+
+type HTTPSongInfoProvider struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewHTTPSongInfoProvider(baseURL string, client *http.Client) *HTTPSongInfoProvider {
+	return &HTTPSongInfoProvider{
+		BaseURL: baseURL,
+		Client:  client,
+	}
+}
+
+func (p *HTTPSongInfoProvider) FetchSongInfo(ctx context.Context, group, song string) (SongDetails, error) {
+	infoURL, err := url.Parse(p.BaseURL)
+	if err != nil {
+		return SongDetails{}, fmt.Errorf("invalid song info API URL: %w", err)
+	}
+	infoURL = infoURL.JoinPath("info")
+	infoURL.RawQuery = url.Values{
+		"group": {group},
+		"song":  {song},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, infoURL.String(), nil)
+	if err != nil {
+		return SongDetails{}, err
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return SongDetails{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return SongDetails{}, ErrSongInfoNotFound
+	default:
+		return SongDetails{}, &SongInfoStatusError{StatusCode: resp.StatusCode}
+	}
+
+	var details SongDetails
+	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
+		return SongDetails{}, fmt.Errorf("failed to parse song info API response: %w", err)
+	}
+
+	return details, nil
+}
+
+// StaticSongInfoProvider serves song details from memory. It is meant for
+// fixtures in tests and local development without the external API.
+type StaticSongInfoProvider struct {
+	songs map[string]SongDetails
+}
+
+func NewStaticSongInfoProvider() *StaticSongInfoProvider {
+	return &StaticSongInfoProvider{
+		songs: make(map[string]SongDetails),
+	}
+}
+
+// NewStaticSongInfoProviderFromFile loads fixtures from a JSON array of
+// objects with group, song, releaseDate, text and link keys.
+func NewStaticSongInfoProviderFromFile(path string) (*StaticSongInfoProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixtures []struct {
+		Group string `json:"group"`
+		Song  string `json:"song"`
+		SongDetails
+	}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse song info fixtures: %w", err)
+	}
+
+	provider := NewStaticSongInfoProvider()
+	for _, fixture := range fixtures {
+		provider.Add(fixture.Group, fixture.Song, fixture.SongDetails)
+	}
+
+	return provider, nil
+}
+
+func (p *StaticSongInfoProvider) Add(group, song string, details SongDetails) {
+	p.songs[songInfoKey(group, song)] = details
+}
+
+func (p *StaticSongInfoProvider) FetchSongInfo(ctx context.Context, group, song string) (SongDetails, error) {
+	details, ok := p.songs[songInfoKey(group, song)]
+	if !ok {
+		return SongDetails{}, ErrSongInfoNotFound
+	}
+	return details, nil
+}
+
+// ChainSongInfoProvider asks each provider in turn and returns the first
+// answer. If none of them knows the song, the last error is returned.
+type ChainSongInfoProvider []SongInfoProvider
+
+func (c ChainSongInfoProvider) FetchSongInfo(ctx context.Context, group, song string) (SongDetails, error) {
+	err := ErrSongInfoNotFound
+	for _, provider := range c {
+		var details SongDetails
+		details, err = provider.FetchSongInfo(ctx, group, song)
+		if err == nil {
+			return details, nil
+		}
+	}
+	return SongDetails{}, err
+}
+
+// songInfoKey normalizes a group/song pair so that lookups ignore case and
+// surrounding or repeated whitespace.
+func songInfoKey(group, song string) string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+	return normalize(group) + "\x1f" + normalize(song)
+}
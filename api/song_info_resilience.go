@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type RetryPolicy struct {
+	// Retries is the number of extra attempts after the first one.
+	Retries   int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// backoff returns the delay before retry number attempt (starting at 0):
+// an exponentially growing ceiling with the upper half jittered.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// ResilientSongInfoProvider wraps another provider with a per-call timeout,
+// retries on network errors and 5xx answers, and a circuit breaker that
+// fails fast while the upstream keeps failing. TotalTimeout bounds all
+// attempts and backoffs together.
+type ResilientSongInfoProvider struct {
+	Provider     SongInfoProvider
+	Timeout      time.Duration
+	TotalTimeout time.Duration
+	Retry        RetryPolicy
+	Breaker      *CircuitBreaker
+}
+
+func (p *ResilientSongInfoProvider) FetchSongInfo(ctx context.Context, group, song string) (SongDetails, error) {
+	if err := p.Breaker.Allow(); err != nil {
+		return SongDetails{}, err
+	}
+
+	attemptsCtx := ctx
+	if p.TotalTimeout > 0 {
+		var cancel context.CancelFunc
+		attemptsCtx, cancel = context.WithTimeout(ctx, p.TotalTimeout)
+		defer cancel()
+	}
+
+	var err error
+attempts:
+	for attempt := 0; ; attempt++ {
+		var details SongDetails
+		details, err = p.fetchOnce(attemptsCtx, group, song)
+		if err == nil || errors.Is(err, ErrSongInfoNotFound) {
+			// Only a definite answer, even a 404, means the upstream is
+			// healthy. Garbage and unexpected statuses count as failures.
+			p.Breaker.Success()
+			return details, err
+		}
+		if ctx.Err() != nil {
+			p.Breaker.Release()
+			return SongDetails{}, ctx.Err()
+		}
+		if !isRetryableSongInfoError(err) || attempt >= p.Retry.Retries {
+			break
+		}
+
+		delay := p.Retry.backoff(attempt)
+		if deadline, ok := attemptsCtx.Deadline(); ok && time.Until(deadline) <= delay {
+			break
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-attemptsCtx.Done():
+			timer.Stop()
+			if ctx.Err() != nil {
+				p.Breaker.Release()
+				return SongDetails{}, ctx.Err()
+			}
+			break attempts
+		case <-timer.C:
+		}
+	}
+
+	p.Breaker.Failure()
+	return SongDetails{}, err
+}
+
+func (p *ResilientSongInfoProvider) fetchOnce(ctx context.Context, group, song string) (SongDetails, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	return p.Provider.FetchSongInfo(ctx, group, song)
+}
+
+// clock stands in for time.Now so that tests can move time forward; the
+// zero value is the wall clock.
+type clock func() time.Time
+
+func (c clock) Now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c()
+}
+
+func isRetryableSongInfoError(err error) bool {
+	var statusErr *SongInfoStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError ||
+			statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// CircuitOpenError is returned without calling the upstream while the
+// breaker is open.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("song info API circuit is open, retry after %s", e.RetryAfter)
+}
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+type BreakerStats struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Threshold           int          `json:"threshold"`
+	Cooldown            string       `json:"cooldown"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	Successes           int64        `json:"successes"`
+	Failures            int64        `json:"failures"`
+	Rejected            int64        `json:"rejected"`
+}
+
+// CircuitBreaker opens after Threshold consecutive failed calls and stays
+// open for Cooldown. After that a single probe call is let through: its
+// success closes the breaker, its failure opens it again.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	now                 clock
+	mu                  sync.Mutex
+	state               BreakerState
+	consecutiveFailures int
+	openedAt            time.Time
+	probeInFlight       bool
+	successes           int64
+	failures            int64
+	rejected            int64
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow reports whether a call may go to the upstream. Every allowed call
+// must be followed by Success, Failure or Release.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if wait := b.openedAt.Add(b.Cooldown).Sub(b.now.Now()); wait > 0 {
+			b.rejected++
+			return &CircuitOpenError{RetryAfter: wait}
+		}
+		b.state = BreakerHalfOpen
+	}
+
+	if b.state == BreakerHalfOpen {
+		if b.probeInFlight {
+			b.rejected++
+			return &CircuitOpenError{RetryAfter: b.Cooldown}
+		}
+		b.probeInFlight = true
+	}
+
+	return nil
+}
+
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.successes++
+	b.consecutiveFailures = 0
+	b.probeInFlight = false
+	b.state = BreakerClosed
+}
+
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.consecutiveFailures++
+	b.probeInFlight = false
+	if b.state == BreakerHalfOpen || b.consecutiveFailures >= b.Threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now.Now()
+	}
+}
+
+// Release gives back an allowed call that ended without a verdict on the
+// upstream, e.g. because the caller went away.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probeInFlight = false
+}
+
+func (b *CircuitBreaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BreakerStats{
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		Threshold:           b.Threshold,
+		Cooldown:            b.Cooldown.String(),
+		Successes:           b.successes,
+		Failures:            b.failures,
+		Rejected:            b.rejected,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+	}
+
+	return stats
+}
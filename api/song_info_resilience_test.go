@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeSongInfoProvider answers with fetch, passing the number of earlier
+// calls.
+type fakeSongInfoProvider struct {
+	mu    sync.Mutex
+	calls int
+	fetch func(ctx context.Context, call int) (SongDetails, error)
+}
+
+func (p *fakeSongInfoProvider) FetchSongInfo(ctx context.Context, group, song string) (SongDetails, error) {
+	p.mu.Lock()
+	call := p.calls
+	p.calls++
+	p.mu.Unlock()
+	return p.fetch(ctx, call)
+}
+
+func (p *fakeSongInfoProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+// answers returns a fetch that replies with errs in turn and with details
+// once they run out.
+func answers(details SongDetails, errs ...error) func(context.Context, int) (SongDetails, error) {
+	return func(ctx context.Context, call int) (SongDetails, error) {
+		if call < len(errs) && errs[call] != nil {
+			return SongDetails{}, errs[call]
+		}
+		return details, nil
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{70, time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 50 {
+			got := policy.backoff(tt.attempt)
+			if got < tt.ceiling/2 || got > tt.ceiling {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s]", tt.attempt, got, tt.ceiling/2, tt.ceiling)
+			}
+		}
+	}
+
+	if got := (RetryPolicy{}).backoff(2); got != 0 {
+		t.Errorf("zero policy backoff = %s, want 0", got)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clk := newFakeClock()
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = clk.Now
+
+	allow := func() {
+		t.Helper()
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow = %v, want nil", err)
+		}
+	}
+	reject := func(retryAfter time.Duration) {
+		t.Helper()
+		var openErr *CircuitOpenError
+		if err := b.Allow(); !errors.As(err, &openErr) || openErr.RetryAfter != retryAfter {
+			t.Fatalf("Allow = %v, want open with retry after %s", err, retryAfter)
+		}
+	}
+	state := func(want BreakerState) {
+		t.Helper()
+		if got := b.Stats().State; got != want {
+			t.Fatalf("state = %s, want %s", got, want)
+		}
+	}
+
+	// A success in between resets the count of consecutive failures.
+	allow()
+	b.Failure()
+	allow()
+	b.Success()
+	allow()
+	b.Failure()
+	state(BreakerClosed)
+
+	allow()
+	b.Failure()
+	state(BreakerOpen)
+	reject(time.Minute)
+
+	clk.Advance(40 * time.Second)
+	reject(20 * time.Second)
+
+	// After the cooldown a single probe goes through.
+	clk.Advance(20 * time.Second)
+	allow()
+	state(BreakerHalfOpen)
+	reject(time.Minute)
+
+	// A failed probe opens the breaker again at once.
+	b.Failure()
+	state(BreakerOpen)
+	reject(time.Minute)
+
+	// A released probe lets the next call probe instead.
+	clk.Advance(time.Minute)
+	allow()
+	b.Release()
+	state(BreakerHalfOpen)
+	allow()
+
+	b.Success()
+	state(BreakerClosed)
+	allow()
+	allow()
+
+	stats := b.Stats()
+	if stats.Successes != 2 || stats.Failures != 4 || stats.Rejected != 4 || stats.ConsecutiveFailures != 0 {
+		t.Errorf("stats = %+v, want 2 successes, 4 failures, 4 rejected", stats)
+	}
+	if stats.OpenedAt != nil {
+		t.Errorf("opened_at = %v on a closed breaker", stats.OpenedAt)
+	}
+}
+
+func TestResilientSongInfoProvider(t *testing.T) {
+	details := SongDetails{ReleaseDate: "16.07.2006", Text: "Ooh baby", Link: "https://example.com"}
+	unavailable := &SongInfoStatusError{StatusCode: http.StatusServiceUnavailable}
+	badRequest := &SongInfoStatusError{StatusCode: http.StatusBadRequest}
+	garbage := errors.New("failed to parse song info API response")
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+		successes int64
+		failures  int64
+	}{
+		{name: "success", wantCalls: 1, successes: 1},
+		{name: "not found is an answer", errs: []error{ErrSongInfoNotFound}, wantErr: ErrSongInfoNotFound, wantCalls: 1, successes: 1},
+		{name: "retried until success", errs: []error{unavailable, unavailable}, wantCalls: 3, successes: 1},
+		{name: "too many requests is retried", errs: []error{&SongInfoStatusError{StatusCode: http.StatusTooManyRequests}}, wantCalls: 2, successes: 1},
+		{name: "retries run out", errs: []error{unavailable, unavailable, unavailable, unavailable}, wantErr: unavailable, wantCalls: 3, failures: 1},
+		{name: "client error is not retried", errs: []error{badRequest}, wantErr: badRequest, wantCalls: 1, failures: 1},
+		{name: "garbage is not retried", errs: []error{garbage}, wantErr: garbage, wantCalls: 1, failures: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &fakeSongInfoProvider{fetch: answers(details, tt.errs...)}
+			p := &ResilientSongInfoProvider{
+				Provider: upstream,
+				Retry:    RetryPolicy{Retries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+				Breaker:  NewCircuitBreaker(5, time.Minute),
+			}
+
+			got, err := p.FetchSongInfo(context.Background(), "Muse", "Starlight")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FetchSongInfo error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != details {
+				t.Errorf("FetchSongInfo = %+v, want %+v", got, details)
+			}
+			if upstream.Calls() != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", upstream.Calls(), tt.wantCalls)
+			}
+			stats := p.Breaker.Stats()
+			if stats.Successes != tt.successes || stats.Failures != tt.failures {
+				t.Errorf("breaker = %d successes, %d failures, want %d and %d",
+					stats.Successes, stats.Failures, tt.successes, tt.failures)
+			}
+		})
+	}
+}
+
+func TestResilientSongInfoProviderOpenBreaker(t *testing.T) {
+	upstream := &fakeSongInfoProvider{fetch: answers(SongDetails{})}
+	p := &ResilientSongInfoProvider{Provider: upstream, Breaker: NewCircuitBreaker(1, time.Minute)}
+	p.Breaker.Failure()
+
+	var openErr *CircuitOpenError
+	if _, err := p.FetchSongInfo(context.Background(), "Muse", "Starlight"); !errors.As(err, &openErr) {
+		t.Fatalf("FetchSongInfo error = %v, want an open circuit", err)
+	}
+	if upstream.Calls() != 0 {
+		t.Errorf("upstream calls = %d, want none", upstream.Calls())
+	}
+}
+
+func TestResilientSongInfoProviderTotalTimeout(t *testing.T) {
+	upstream := &fakeSongInfoProvider{fetch: answers(SongDetails{},
+		&SongInfoStatusError{StatusCode: http.StatusBadGateway},
+		&SongInfoStatusError{StatusCode: http.StatusBadGateway},
+		&SongInfoStatusError{StatusCode: http.StatusBadGateway},
+	)}
+	p := &ResilientSongInfoProvider{
+		Provider:     upstream,
+		TotalTimeout: 100 * time.Millisecond,
+		// The first backoff alone would outlast the total timeout.
+		Retry:   RetryPolicy{Retries: 3, BaseDelay: 300 * time.Millisecond, MaxDelay: time.Second},
+		Breaker: NewCircuitBreaker(5, time.Minute),
+	}
+
+	start := time.Now()
+	_, err := p.FetchSongInfo(context.Background(), "Muse", "Starlight")
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("FetchSongInfo took %s, want no wait past the deadline", elapsed)
+	}
+	var statusErr *SongInfoStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("FetchSongInfo error = %v, want the upstream status", err)
+	}
+	if upstream.Calls() != 1 {
+		t.Errorf("upstream calls = %d, want 1", upstream.Calls())
+	}
+	if stats := p.Breaker.Stats(); stats.Failures != 1 {
+		t.Errorf("breaker failures = %d, want 1", stats.Failures)
+	}
+}
+
+func TestResilientSongInfoProviderSlowAttempts(t *testing.T) {
+	// Every attempt hangs until its own timeout; the total timeout cuts
+	// the retries short.
+	upstream := &fakeSongInfoProvider{fetch: func(ctx context.Context, call int) (SongDetails, error) {
+		<-ctx.Done()
+		return SongDetails{}, ctx.Err()
+	}}
+	p := &ResilientSongInfoProvider{
+		Provider:     upstream,
+		Timeout:      40 * time.Millisecond,
+		TotalTimeout: 100 * time.Millisecond,
+		Retry:        RetryPolicy{Retries: 10, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		Breaker:      NewCircuitBreaker(5, time.Minute),
+	}
+
+	start := time.Now()
+	_, err := p.FetchSongInfo(context.Background(), "Muse", "Starlight")
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("FetchSongInfo took %s, want about the total timeout", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FetchSongInfo error = %v, want a deadline error", err)
+	}
+	if calls := upstream.Calls(); calls < 2 || calls > 3 {
+		t.Errorf("upstream calls = %d, want 2 or 3", calls)
+	}
+	if stats := p.Breaker.Stats(); stats.Failures != 1 {
+		t.Errorf("breaker failures = %d, want 1", stats.Failures)
+	}
+}
+
+func TestResilientSongInfoProviderCallerCancels(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	upstream := &fakeSongInfoProvider{fetch: func(ctx context.Context, call int) (SongDetails, error) {
+		cancel()
+		return SongDetails{}, ctx.Err()
+	}}
+	p := &ResilientSongInfoProvider{
+		Provider: upstream,
+		Retry:    RetryPolicy{Retries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		Breaker:  NewCircuitBreaker(1, time.Minute),
+	}
+
+	if _, err := p.FetchSongInfo(ctx, "Muse", "Starlight"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("FetchSongInfo error = %v, want context.Canceled", err)
+	}
+	if upstream.Calls() != 1 {
+		t.Errorf("upstream calls = %d, want 1", upstream.Calls())
+	}
+	stats := p.Breaker.Stats()
+	if stats.State != BreakerClosed || stats.Successes != 0 || stats.Failures != 0 {
+		t.Errorf("breaker = %+v, want it untouched", stats)
+	}
+}
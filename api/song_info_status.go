@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/par1ram/song-library/common"
+)
+
+type SongInfoStatusResponse struct {
+	Breaker BreakerStats        `json:"breaker"`
+	Cache   *SongInfoCacheStats `json:"cache,omitempty"`
+}
+
+func (cfg *ApiConfig) SongInfoStatus(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Debug("SongInfoStatus called")
+
+	result := SongInfoStatusResponse{
+		Breaker: cfg.SongInfoBreaker.Stats(),
+	}
+	if cfg.SongInfoCache != nil {
+		stats := cfg.SongInfoCache.Stats()
+		result.Cache = &stats
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/par1ram/song-library/common"
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+const resyncBatchSize = 100
+
+const (
+	ResyncUnchanged = "unchanged"
+	ResyncChanged   = "changed"
+	ResyncUpdated   = "updated"
+	ResyncConflict  = "conflict"
+	ResyncFailed    = "failed"
+)
+
+// ResyncScope selects the songs to refresh: a single song, every song of
+// a group, or (with neither set) the whole catalogue.
+type ResyncScope struct {
+	SongID  int32
+	GroupID int32
+}
+
+type ResyncFieldDiff struct {
+	Old *string `json:"old"`
+	New *string `json:"new"`
+}
+
+type ResyncSongResult struct {
+	ID        int32                      `json:"id"`
+	GroupName string                     `json:"group_name"`
+	SongName  string                     `json:"song_name"`
+	Status    string                     `json:"status"`
+	Changes   map[string]ResyncFieldDiff `json:"changes,omitempty"`
+	Error     string                     `json:"error,omitempty"`
+}
+
+// ResyncReport counts every checked song but only lists the ones that
+// differ from the external API or could not be refreshed.
+type ResyncReport struct {
+	DryRun    bool               `json:"dry_run"`
+	Checked   int                `json:"checked"`
+	Unchanged int                `json:"unchanged"`
+	Changed   int                `json:"changed"`
+	Conflicts int                `json:"conflicts"`
+	Failed    int                `json:"failed"`
+	Aborted   string             `json:"aborted,omitempty"`
+	Songs     []ResyncSongResult `json:"songs"`
+}
+
+// RunResync re-queries the external API for the songs in scope and
+// reports field-level differences. With apply set the differences are
+// written back to the database.
+func (cfg *ApiConfig) RunResync(ctx context.Context, scope ResyncScope, apply bool) (ResyncReport, error) {
+	report := ResyncReport{
+		DryRun: !apply,
+		Songs:  []ResyncSongResult{},
+	}
+
+	params := database.ListSongsForResyncParams{
+		SongID:    sql.NullInt32{Int32: scope.SongID, Valid: scope.SongID > 0},
+		GroupID:   sql.NullInt32{Int32: scope.GroupID, Valid: scope.GroupID > 0},
+		BatchSize: resyncBatchSize,
+	}
+
+	for {
+		songs, err := cfg.DB.ListSongsForResync(ctx, params)
+		if err != nil {
+			return report, err
+		}
+
+		for _, song := range songs {
+			result := cfg.resyncSong(ctx, song, apply)
+			report.Checked++
+
+			switch result.Status {
+			case ResyncUnchanged:
+				report.Unchanged++
+				continue
+			case ResyncConflict:
+				report.Conflicts++
+			case ResyncFailed:
+				report.Failed++
+			default:
+				report.Changed++
+			}
+			report.Songs = append(report.Songs, result.ResyncSongResult)
+
+			var openErr *CircuitOpenError
+			if result.Status == ResyncFailed && errors.As(result.err, &openErr) {
+				report.Aborted = "external API is unavailable: " + openErr.Error()
+				return report, nil
+			}
+		}
+
+		if len(songs) < resyncBatchSize {
+			return report, nil
+		}
+		params.AfterID = songs[len(songs)-1].ID
+	}
+}
+
+type resyncSongResult struct {
+	ResyncSongResult
+	err error
+}
+
+func (cfg *ApiConfig) resyncSong(ctx context.Context, song database.ListSongsForResyncRow, apply bool) resyncSongResult {
+	result := resyncSongResult{
+		ResyncSongResult: ResyncSongResult{
+			ID:        song.ID,
+			GroupName: song.GroupName,
+			SongName:  song.SongName,
+		},
+	}
+	logger := cfg.Logger.WithField("song_id", song.ID)
+
+	// A resync must see what the external API says now, not a cached answer.
+	details, err := cfg.SongInfo.FetchSongInfo(withSongInfoCacheBypass(ctx), song.GroupName, song.SongName)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to fetch song details for resync")
+		result.Status = ResyncFailed
+		result.Error = err.Error()
+		result.err = err
+		return result
+	}
+
+	releaseDate, text, link := songDetailsColumns(details)
+	changes := make(map[string]ResyncFieldDiff)
+	addDiff := func(field string, oldValue, newValue *string) {
+		if !equalStringPtr(oldValue, newValue) {
+			changes[field] = ResyncFieldDiff{Old: oldValue, New: newValue}
+		}
+	}
+	addDiff("release_date", nullDateToPtr(song.ReleaseDate), nullDateToPtr(releaseDate))
+	addDiff("text", nullStringToPtr(song.Text), nullStringToPtr(text))
+	addDiff("link", nullStringToPtr(song.Link), nullStringToPtr(link))
+
+	if len(changes) == 0 {
+		result.Status = ResyncUnchanged
+		return result
+	}
+	result.Changes = changes
+	result.Status = ResyncChanged
+
+	if apply {
+		updated, err := cfg.DB.UpdateSongEnrichment(ctx, database.UpdateSongEnrichmentParams{
+			ID:          song.ID,
+			ReleaseDate: releaseDate,
+			Text:        text,
+			Link:        link,
+			Version:     song.Version,
+		})
+		if err != nil {
+			logger.WithError(err).Error("Failed to apply resync changes")
+			result.Status = ResyncFailed
+			result.Error = "failed to apply changes"
+			result.err = err
+			return result
+		}
+		if updated == 0 {
+			// The song was edited or deleted after it was compared.
+			logger.Warn("Song changed during resync, changes not applied")
+			result.Status = ResyncConflict
+			result.Error = "song was modified during resync, run it again"
+			return result
+		}
+		result.Status = ResyncUpdated
+		logger.WithField("fields", len(changes)).Info("Song resynced")
+	}
+
+	return result
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+type ResyncRequest struct {
+	SongID  int32  `json:"song_id,omitempty"`
+	GroupID int32  `json:"group_id,omitempty"`
+	Group   string `json:"group,omitempty"`
+	All     bool   `json:"all,omitempty"`
+	DryRun  *bool  `json:"dry_run,omitempty"`
+}
+
+func (cfg *ApiConfig) ResyncSongs(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("ResyncSongs called")
+
+	var req ResyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		cfg.Logger.WithError(err).Error("Invalid request payload")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid request payload"))
+		return
+	}
+
+	scopes := 0
+	for _, set := range []bool{req.SongID > 0, req.GroupID > 0 || req.Group != "", req.All} {
+		if set {
+			scopes++
+		}
+	}
+	if scopes != 1 {
+		cfg.Logger.Error("Invalid resync scope")
+		apierr.Write(w, r, apierr.NewBadRequest("Specify exactly one of song_id, group_id/group or all"))
+		return
+	}
+
+	scope := ResyncScope{SongID: req.SongID, GroupID: req.GroupID}
+	if req.Group != "" && req.GroupID == 0 {
+		groupID, err := cfg.DB.GetGroupIDByGroupName(r.Context(), req.Group)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				cfg.respondGroupNotFound(w, r, req.Group)
+				return
+			}
+			cfg.Logger.WithError(err).Error("Failed to look up group")
+			apierr.Write(w, r, apierr.NewInternal("Failed to look up group", err))
+			return
+		}
+		scope.GroupID = groupID
+	}
+
+	dryRun := true
+	if req.DryRun != nil {
+		dryRun = *req.DryRun
+	}
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"song_id":  scope.SongID,
+		"group_id": scope.GroupID,
+		"dry_run":  dryRun,
+	}).Debug("Resyncing songs")
+
+	// A resync of a large group or the whole catalogue easily outlives the
+	// server's WriteTimeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		cfg.Logger.WithError(err).Warn("Failed to lift write deadline")
+	}
+
+	report, err := cfg.RunResync(r.Context(), scope, !dryRun)
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to resync songs")
+		apierr.Write(w, r, apierr.NewInternal("Failed to resync songs", err))
+		return
+	}
+	if scope.SongID > 0 && report.Checked == 0 {
+		cfg.Logger.WithField("song_id", scope.SongID).Warn("Song not found")
+		apierr.Write(w, r, apierr.NewNotFound("Song not found"))
+		return
+	}
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"checked":   report.Checked,
+		"changed":   report.Changed,
+		"conflicts": report.Conflicts,
+		"failed":    report.Failed,
+	}).Info("Songs resynced")
+	common.RespondWithJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/par1ram/song-library/common"
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/par1ram/song-library/internal/validate"
+	"github.com/sirupsen/logrus"
+)
+
+type SearchSongResponse struct {
+	ID          int32   `json:"id"`
+	GroupName   string  `json:"group_name"`
+	SongName    string  `json:"song_name"`
+	ReleaseDate *string `json:"release_date"`
+	Link        *string `json:"link"`
+	Rank        float32 `json:"rank"`
+	Snippet     string  `json:"snippet"`
+}
+
+func newSearchSongResponse(row database.SearchSongsRow) SearchSongResponse {
+	return SearchSongResponse{
+		ID:          row.ID,
+		GroupName:   row.GroupName,
+		SongName:    row.SongName,
+		ReleaseDate: nullDateToPtr(row.ReleaseDate),
+		Link:        nullStringToPtr(row.Link),
+		Rank:        row.Rank,
+		Snippet:     row.Snippet,
+	}
+}
+
+func (cfg *ApiConfig) SearchSongs(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("SearchSongs called")
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		cfg.Logger.Error("Empty search query")
+		apierr.Write(w, r, apierr.NewBadRequest("Search query is required"))
+		return
+	}
+
+	config := r.URL.Query().Get("config")
+	if config == "" {
+		config = cfg.SearchConfig
+	}
+	if !slices.Contains(common.SearchConfigs, config) {
+		cfg.Logger.WithField("config", config).Error("Unsupported search configuration")
+		apierr.Write(w, r, apierr.NewBadRequest("Unsupported search configuration, use one of: "+strings.Join(common.SearchConfigs, ", ")))
+		return
+	}
+
+	limit, err := parseQueryInt32(r, "limit", 10)
+	if err != nil || limit <= 0 {
+		cfg.Logger.WithError(err).Error("Invalid limit")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid limit"))
+		return
+	}
+
+	offset, err := parseQueryInt32(r, "offset", 0)
+	if err != nil || offset < 0 {
+		cfg.Logger.WithError(err).Error("Invalid offset")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid offset"))
+		return
+	}
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"query":  query,
+		"config": config,
+		"limit":  limit,
+		"offset": offset,
+	}).Debug("Searching songs")
+
+	rows, err := cfg.DB.SearchSongs(r.Context(), database.SearchSongsParams{
+		Config:     config,
+		Query:      query,
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		cfg.Logger.WithError(err).Error("Failed to search songs")
+		apierr.Write(w, r, apierr.NewInternal("Failed to search songs", err))
+		return
+	}
+
+	result := make([]SearchSongResponse, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, newSearchSongResponse(row))
+	}
+
+	cfg.Logger.WithField("song_count", len(result)).Info("Searched songs successfully")
+	common.RespondWithJSON(w, http.StatusOK, result)
+}
+
+func nullDateToPtr(date sql.NullTime) *string {
+	if !date.Valid {
+		return nil
+	}
+	formatted := date.Time.Format(validate.DateLayout)
+	return &formatted
+}
+
+func nullStringToPtr(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/par1ram/song-library/internal/validate"
+)
+
+// The song writes below are shared by the single song handlers and
+// BulkSongs. They run on q, which may be bound to a transaction, and fail
+// with errors that can be shown to the client. versions comes from
+// parseIfMatch; the writes return the new version of the song.
+
+func updateSong(ctx context.Context, q *database.Queries, req UpdateSongRequest, versions []int32) (int32, error) {
+	if err := validationError(req); err != nil {
+		return 0, err
+	}
+	if err := checkGroupExists(ctx, q, "group_id", req.GroupID); err != nil {
+		return 0, err
+	}
+
+	var releaseDate sql.NullTime
+	if req.ReleaseDate != "" {
+		parsedDate, _ := time.Parse(validate.DateLayout, req.ReleaseDate)
+		releaseDate = sql.NullTime{Time: parsedDate, Valid: true}
+	}
+
+	version, err := q.UpdateSong(ctx, database.UpdateSongParams{
+		ID:          req.ID,
+		GroupID:     req.GroupID,
+		SongName:    strings.TrimSpace(req.SongName),
+		Text:        sql.NullString{String: req.Text, Valid: req.Text != ""},
+		ReleaseDate: releaseDate,
+		Link:        sql.NullString{String: req.Link, Valid: req.Link != ""},
+		Versions:    versions,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, songNotWritten(ctx, q, req.ID, versions)
+	}
+	if err != nil {
+		return 0, songWriteError("Failed to update song", err)
+	}
+	return version, nil
+}
+
+func patchSong(ctx context.Context, q *database.Queries, id int32, req PatchSongRequest, versions []int32) (int32, error) {
+	if err := validationError(req); err != nil {
+		return 0, err
+	}
+	if req.GroupID.Present() {
+		if err := checkGroupExists(ctx, q, "group_id", req.GroupID.Value); err != nil {
+			return 0, err
+		}
+	}
+
+	params := req.params(id)
+	params.Versions = versions
+	version, err := q.UpdateSongPartial(ctx, params)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, songNotWritten(ctx, q, id, versions)
+	}
+	if err != nil {
+		return 0, songWriteError("Failed to update song", err)
+	}
+	return version, nil
+}
+
+// jsonPatchSong applies a JSON Patch to the song id. The song is locked
+// first, so test operations see the value that is updated.
+func jsonPatchSong(ctx context.Context, q *database.Queries, id int32, ops []JSONPatchOperation, versions []int32) (int32, error) {
+	version, err := q.LockSong(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, apierr.NewNotFound("Song not found")
+		}
+		return 0, apierr.NewInternal("Failed to fetch song", err)
+	}
+	if versions != nil && !slices.Contains(versions, version) {
+		return 0, errSongModified()
+	}
+	row, err := q.GetSongByID(ctx, id)
+	if err != nil {
+		return 0, apierr.NewInternal("Failed to fetch song", err)
+	}
+
+	req, err := applyJSONPatch(row, ops)
+	if err != nil {
+		return 0, err
+	}
+	return patchSong(ctx, q, id, req, nil)
+}
+
+func deleteSong(ctx context.Context, q *database.Queries, id int32, versions []int32) error {
+	deleted, err := q.DeleteSong(ctx, database.DeleteSongParams{ID: id, Versions: versions})
+	if err != nil {
+		return apierr.NewInternal("Failed to delete song", err)
+	}
+	if deleted == 0 {
+		return songNotWritten(ctx, q, id, versions)
+	}
+	return nil
+}
+
+// songNotWritten explains a write of the song id that matched no row:
+// either the song is gone or its version is not among versions.
+func songNotWritten(ctx context.Context, q *database.Queries, id int32, versions []int32) error {
+	if versions == nil {
+		return apierr.NewNotFound("Song not found")
+	}
+	if _, err := q.GetSongVersion(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apierr.NewNotFound("Song not found")
+		}
+		return apierr.NewInternal("Failed to fetch song", err)
+	}
+	return errSongModified()
+}
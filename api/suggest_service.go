@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/par1ram/song-library/common"
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+const groupNotFoundSuggestionLimit = 5
+
+type GroupSuggestion struct {
+	ID        int32   `json:"id"`
+	GroupName string  `json:"group_name"`
+	Score     float32 `json:"score"`
+}
+
+type SongSuggestion struct {
+	ID        int32   `json:"id"`
+	SongName  string  `json:"song_name"`
+	GroupName string  `json:"group_name"`
+	Score     float32 `json:"score"`
+}
+
+type SuggestResponse struct {
+	Query  string            `json:"query"`
+	Groups []GroupSuggestion `json:"groups,omitempty"`
+	Songs  []SongSuggestion  `json:"songs,omitempty"`
+}
+
+// Suggest ranks groups and/or songs by trigram similarity to q, so that
+// misspelled names ("Led Zepelin") still find their match.
+func (cfg *ApiConfig) Suggest(w http.ResponseWriter, r *http.Request) {
+	cfg.Logger.Info("Suggest called")
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		cfg.Logger.Error("Empty suggest query")
+		apierr.Write(w, r, apierr.NewBadRequest("Query is required"))
+		return
+	}
+
+	kind := r.URL.Query().Get("type")
+	if kind != "" && kind != "group" && kind != "song" {
+		cfg.Logger.WithField("type", kind).Error("Invalid suggestion type")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid type, use group or song"))
+		return
+	}
+
+	limit, err := parseQueryInt32(r, "limit", 5)
+	if err != nil || limit <= 0 {
+		cfg.Logger.WithError(err).Error("Invalid limit")
+		apierr.Write(w, r, apierr.NewBadRequest("Invalid limit"))
+		return
+	}
+
+	cfg.Logger.WithFields(logrus.Fields{
+		"query": query,
+		"type":  kind,
+		"limit": limit,
+	}).Debug("Looking up suggestions")
+
+	result := SuggestResponse{Query: query}
+
+	if kind == "" || kind == "group" {
+		groups, err := cfg.DB.SuggestGroups(r.Context(), database.SuggestGroupsParams{
+			Similarity: query,
+			Limit:      limit,
+		})
+		if err != nil {
+			cfg.Logger.WithError(err).Error("Failed to fetch group suggestions")
+			apierr.Write(w, r, apierr.NewInternal("Failed to fetch suggestions", err))
+			return
+		}
+		result.Groups = make([]GroupSuggestion, 0, len(groups))
+		for _, group := range groups {
+			result.Groups = append(result.Groups, GroupSuggestion{
+				ID:        group.ID,
+				GroupName: group.GroupName,
+				Score:     group.Score,
+			})
+		}
+	}
+
+	if kind == "" || kind == "song" {
+		songs, err := cfg.DB.SuggestSongs(r.Context(), database.SuggestSongsParams{
+			Similarity: query,
+			Limit:      limit,
+		})
+		if err != nil {
+			cfg.Logger.WithError(err).Error("Failed to fetch song suggestions")
+			apierr.Write(w, r, apierr.NewInternal("Failed to fetch suggestions", err))
+			return
+		}
+		result.Songs = make([]SongSuggestion, 0, len(songs))
+		for _, song := range songs {
+			result.Songs = append(result.Songs, SongSuggestion{
+				ID:        song.ID,
+				SongName:  song.SongName,
+				GroupName: song.GroupName,
+				Score:     song.Score,
+			})
+		}
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, result)
+}
+
+// suggestGroupNames is best effort: a failed lookup only costs the caller
+// the "did you mean" hint, so errors are logged and swallowed.
+func (cfg *ApiConfig) suggestGroupNames(ctx context.Context, groupName string) []string {
+	groups, err := cfg.DB.SuggestGroups(ctx, database.SuggestGroupsParams{
+		Similarity: groupName,
+		Limit:      groupNotFoundSuggestionLimit,
+	})
+	if err != nil {
+		cfg.Logger.WithError(err).Warn("Failed to fetch group suggestions")
+		return nil
+	}
+
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.GroupName)
+	}
+	return names
+}
+
+func (cfg *ApiConfig) respondGroupNotFound(w http.ResponseWriter, r *http.Request, groupName string) {
+	cfg.Logger.WithField("group", groupName).Error("Group not found")
+
+	apierr.Write(w, r, apierr.NewNotFound("Group not found").
+		With("suggestions", cfg.suggestGroupNames(r.Context(), groupName)))
+}
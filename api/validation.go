@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/database"
+	"github.com/par1ram/song-library/internal/validate"
+)
+
+// validationError checks req against its validate tags.
+func validationError(req any) error {
+	if errs := validate.Struct(req); len(errs) > 0 {
+		return apierr.NewValidation(errs...)
+	}
+	return nil
+}
+
+// validateRequest checks req against its validate tags and responds with
+// the field errors if there are any.
+func (cfg *ApiConfig) validateRequest(w http.ResponseWriter, r *http.Request, req any) bool {
+	err := validationError(req)
+	if err == nil {
+		return true
+	}
+
+	cfg.Logger.WithError(err).Error("Request validation failed")
+	apierr.Write(w, r, err)
+	return false
+}
+
+// checkGroupExists reports a missing group as an unprocessable field, so a
+// song is never pointed at a group that does not exist.
+func checkGroupExists(ctx context.Context, q *database.Queries, field string, groupID int32) error {
+	if _, err := q.GetGroupByID(ctx, groupID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errGroupNotFound(field)
+		}
+		return apierr.NewInternal("Failed to look up group", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	api "github.com/par1ram/song-library/api"
+)
+
+// runCommand executes a batch subcommand instead of starting the server.
+func runCommand(apiCfg *api.ApiConfig, name string, args []string) error {
+	switch name {
+	case "resync":
+		return runResync(apiCfg, args)
+	case "import":
+		return runImport(apiCfg, args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+func runResync(apiCfg *api.ApiConfig, args []string) error {
+	flags := flag.NewFlagSet("resync", flag.ExitOnError)
+	songID := flags.Int("song", 0, "ID of the song to resync")
+	group := flags.String("group", "", "name of the group whose songs to resync")
+	all := flags.Bool("all", false, "resync the whole catalogue")
+	apply := flags.Bool("apply", false, "write changes to the database (dry run otherwise)")
+	flags.Parse(args)
+
+	scopes := 0
+	for _, set := range []bool{*songID > 0, *group != "", *all} {
+		if set {
+			scopes++
+		}
+	}
+	if scopes != 1 {
+		return fmt.Errorf("specify exactly one of -song, -group or -all")
+	}
+
+	ctx := context.Background()
+	scope := api.ResyncScope{SongID: int32(*songID)}
+	if *group != "" {
+		groupID, err := apiCfg.DB.GetGroupIDByGroupName(ctx, *group)
+		if err != nil {
+			return fmt.Errorf("failed to look up group %q: %w", *group, err)
+		}
+		scope.GroupID = groupID
+	}
+
+	report, err := apiCfg.RunResync(ctx, scope, *apply)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func runImport(apiCfg *api.ApiConfig, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	file := flags.String("file", "", "CSV, JSON or NDJSON file to import, - for stdin")
+	format := flags.String("format", "", "csv, json or ndjson (guessed from the file extension by default)")
+	columns := flags.String("columns", "", "column mapping, e.g. group=artist,song=title")
+	enrich := flags.Bool("enrich", false, "queue songs without details for the external API")
+	flags.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("specify the file to import with -file")
+	}
+	if *format == "" {
+		*format = api.ImportFormat(*file)
+	}
+	mapping, err := api.ParseImportColumns(*columns)
+	if err != nil {
+		return err
+	}
+
+	src := os.Stdin
+	if *file != "-" {
+		if src, err = os.Open(*file); err != nil {
+			return err
+		}
+		defer src.Close()
+	}
+
+	report, err := apiCfg.RunImport(context.Background(), src, api.ImportOptions{
+		Format:  *format,
+		Columns: mapping,
+		Enrich:  *enrich,
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
 	api "github.com/par1ram/song-library/api"
@@ -28,20 +29,48 @@ func main() {
 	dbCon := common.ConnectToDatabase()
 	defer dbCon.Close()
 
-	// Можно выбрать уровень логирования
-	apiCfg := api.NewApiConfig(dbCon, logrus.DebugLevel)
-
 	// Миграции
 	if err := goose.Up(dbCon, "sql/schema"); err != nil {
 		log.Fatalf("Error applying migrations: %v", err)
 	}
 
+	// Можно выбрать уровень логирования
+	apiCfg := api.NewApiConfig(dbCon, logrus.DebugLevel)
+
+	// Пакетные команды, например: go run ./cmd resync -group "Queen" -apply
+	// или go run ./cmd import -file catalogue.csv
+	if len(os.Args) > 1 {
+		if err := runCommand(apiCfg, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("Command %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	// Фоновое заполнение данных песен из внешнего API
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	enrichmentWorker := apiCfg.NewEnrichmentWorker(
+		common.GetEnrichmentWorkers(),
+		common.GetEnrichmentPollInterval(),
+		common.GetEnrichmentLease(),
+		common.GetEnrichmentMaxAttempts(),
+		api.RetryPolicy{
+			BaseDelay: common.GetEnrichmentRetryBaseDelay(),
+			MaxDelay:  common.GetEnrichmentRetryMaxDelay(),
+		},
+	)
+	workersDone := make(chan struct{})
+	go func() {
+		enrichmentWorker.Run(workerCtx)
+		close(workersDone)
+	}()
+
 	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://*", "https://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "Retry-After", "Deprecation", "Content-Disposition", "ETag"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -53,13 +82,41 @@ func main() {
 		httpSwagger.URL("/docs/swagger.yaml"),
 	))
 
-	router.Post("/songs/filter", apiCfg.GetSongWithFiltersAndPagination)
-	router.Post("/songs/verses", apiCfg.GetSongVersesWithPagination)
+	router.Route("/songs", func(r chi.Router) {
+		r.Get("/", apiCfg.ListSongs)
+		r.Post("/", apiCfg.InsertSong)
+		r.Get("/search", apiCfg.SearchSongs)
+		r.Post("/resync", apiCfg.ResyncSongs)
+		r.Post("/bulk", apiCfg.BulkSongs)
+		r.Post("/import", apiCfg.ImportSongs)
+
+		r.Get("/{id}", apiCfg.GetSong)
+		r.Put("/{id}", apiCfg.UpdateSong)
+		r.Patch("/{id}", apiCfg.PatchSong)
+		r.Delete("/{id}", apiCfg.DeleteSong)
+		r.Get("/{id}/verses", apiCfg.GetSongVerses)
+	})
+
+	router.Route("/groups", func(r chi.Router) {
+		r.Get("/", apiCfg.ListGroups)
+		r.Post("/", apiCfg.CreateGroup)
+
+		r.Get("/{id}", apiCfg.GetGroup)
+		r.Put("/{id}", apiCfg.RenameGroup)
+		r.Delete("/{id}", apiCfg.DeleteGroup)
+	})
+
+	router.Get("/suggest", apiCfg.Suggest)
+	router.Get("/export", apiCfg.ExportSongs)
+	router.Get("/external/status", apiCfg.SongInfoStatus)
 
-	router.Post("/songs/add", apiCfg.InsertSong)
-	router.Put("/songs/update", apiCfg.UpdateSong)
-	router.Delete("/songs/delete", apiCfg.DeleteSong)
-	router.Patch("/songs/patch", apiCfg.PatchSong)
+	// Устаревшие маршруты, оставлены для совместимости
+	router.With(apiCfg.Deprecated("/songs")).Post("/songs/filter", apiCfg.GetSongWithFiltersAndPagination)
+	router.With(apiCfg.Deprecated("/songs/{id}/verses")).Post("/songs/verses", apiCfg.GetSongVersesWithPagination)
+	router.With(apiCfg.Deprecated("/songs")).Post("/songs/add", apiCfg.InsertSong)
+	router.With(apiCfg.Deprecated("/songs/{id}")).Put("/songs/update", apiCfg.UpdateSong)
+	router.With(apiCfg.Deprecated("/songs/{id}")).Delete("/songs/delete", apiCfg.DeleteSong)
+	router.With(apiCfg.Deprecated("/songs/{id}")).Patch("/songs/patch", apiCfg.PatchSong)
 
 	server := &http.Server{
 		Addr:           ":" + PORT,
@@ -86,5 +143,7 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	stopWorkers()
+	<-workersDone
 	log.Println("Server exiting")
 }
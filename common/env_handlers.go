@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func GetPort() string {
@@ -33,8 +37,175 @@ func GetDatabaseURL() string {
 func GetExternalApiURL() string {
 	EXTERNAL_API_URL := os.Getenv("EXTERNAL_API_URL")
 	if EXTERNAL_API_URL == "" {
-		log.Fatal("Couldnt get port from env")
+		log.Fatal("Couldnt get external API URL from env")
 	}
 
 	return EXTERNAL_API_URL
 }
+
+func GetAutoCreateGroups() bool {
+	AUTO_CREATE_GROUPS := os.Getenv("AUTO_CREATE_GROUPS")
+	if AUTO_CREATE_GROUPS == "" {
+		return false
+	}
+
+	autoCreate, err := strconv.ParseBool(AUTO_CREATE_GROUPS)
+	if err != nil {
+		log.Fatalf("Invalid AUTO_CREATE_GROUPS value: %v", err)
+	}
+
+	return autoCreate
+}
+
+// SearchConfigs lists the text search configurations the search_vector
+// column is built with (see sql/schema/003_songs_search.sql).
+var SearchConfigs = []string{"simple", "english", "russian"}
+
+func GetSearchConfig() string {
+	SEARCH_TS_CONFIG := os.Getenv("SEARCH_TS_CONFIG")
+	if SEARCH_TS_CONFIG == "" {
+		return "english"
+	}
+
+	if !slices.Contains(SearchConfigs, SEARCH_TS_CONFIG) {
+		log.Fatalf("Invalid SEARCH_TS_CONFIG value: %s, use one of: %s", SEARCH_TS_CONFIG, strings.Join(SearchConfigs, ", "))
+	}
+
+	return SEARCH_TS_CONFIG
+}
+
+func GetSongInfoFixturesPath() string {
+	return os.Getenv("SONG_INFO_FIXTURES")
+}
+
+func GetExternalApiTimeout() time.Duration {
+	return getDurationEnv("EXTERNAL_API_TIMEOUT", 3*time.Second)
+}
+
+func GetExternalApiTotalTimeout() time.Duration {
+	return getDurationEnv("EXTERNAL_API_TOTAL_TIMEOUT", 8*time.Second)
+}
+
+func GetExternalApiRetries() int {
+	return getIntEnv("EXTERNAL_API_RETRIES", 2)
+}
+
+func GetExternalApiRetryBaseDelay() time.Duration {
+	return getDurationEnv("EXTERNAL_API_RETRY_BASE_DELAY", 200*time.Millisecond)
+}
+
+func GetExternalApiRetryMaxDelay() time.Duration {
+	return getDurationEnv("EXTERNAL_API_RETRY_MAX_DELAY", 2*time.Second)
+}
+
+func GetExternalApiBreakerThreshold() int {
+	return getIntEnv("EXTERNAL_API_BREAKER_THRESHOLD", 5)
+}
+
+func GetExternalApiBreakerCooldown() time.Duration {
+	return getDurationEnv("EXTERNAL_API_BREAKER_COOLDOWN", 30*time.Second)
+}
+
+func getIntEnv(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Fatalf("Invalid %s value: %v", name, err)
+	}
+
+	return value
+}
+
+func getDurationEnv(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Fatalf("Invalid %s value: %v", name, err)
+	}
+
+	return value
+}
+
+func GetAsyncEnrichment() bool {
+	ENRICHMENT_MODE := os.Getenv("ENRICHMENT_MODE")
+	switch ENRICHMENT_MODE {
+	case "", "sync":
+		return false
+	case "async":
+		return true
+	default:
+		log.Fatalf("Invalid ENRICHMENT_MODE value: %s", ENRICHMENT_MODE)
+		return false
+	}
+}
+
+func GetEnrichmentWorkers() int {
+	return getIntEnv("ENRICHMENT_WORKERS", 2)
+}
+
+func GetEnrichmentPollInterval() time.Duration {
+	return getDurationEnv("ENRICHMENT_POLL_INTERVAL", 2*time.Second)
+}
+
+func GetEnrichmentLease() time.Duration {
+	return getDurationEnv("ENRICHMENT_LEASE", time.Minute)
+}
+
+func GetEnrichmentMaxAttempts() int {
+	return getIntEnv("ENRICHMENT_MAX_ATTEMPTS", 5)
+}
+
+func GetEnrichmentRetryBaseDelay() time.Duration {
+	return getDurationEnv("ENRICHMENT_RETRY_BASE_DELAY", 10*time.Second)
+}
+
+func GetEnrichmentRetryMaxDelay() time.Duration {
+	return getDurationEnv("ENRICHMENT_RETRY_MAX_DELAY", 10*time.Minute)
+}
+
+func GetSongInfoCacheBackend() string {
+	SONG_INFO_CACHE := os.Getenv("SONG_INFO_CACHE")
+	switch SONG_INFO_CACHE {
+	case "":
+		return "memory"
+	case "memory", "postgres", "none":
+		return SONG_INFO_CACHE
+	default:
+		log.Fatalf("Invalid SONG_INFO_CACHE value: %s", SONG_INFO_CACHE)
+		return ""
+	}
+}
+
+func GetSongInfoCacheTTL() time.Duration {
+	return getDurationEnv("SONG_INFO_CACHE_TTL", 24*time.Hour)
+}
+
+func GetSongInfoCacheNegativeTTL() time.Duration {
+	return getDurationEnv("SONG_INFO_CACHE_NEGATIVE_TTL", 10*time.Minute)
+}
+
+func GetSongInfoCacheSize() int {
+	return getIntEnv("SONG_INFO_CACHE_SIZE", 10000)
+}
+
+func GetRequireIfMatch() bool {
+	REQUIRE_IF_MATCH := os.Getenv("REQUIRE_IF_MATCH")
+	if REQUIRE_IF_MATCH == "" {
+		return false
+	}
+
+	require, err := strconv.ParseBool(REQUIRE_IF_MATCH)
+	if err != nil {
+		log.Fatalf("Invalid REQUIRE_IF_MATCH value: %v", err)
+	}
+
+	return require
+}
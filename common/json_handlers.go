@@ -2,24 +2,9 @@ package common
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 )
 
-func RespondWithError(w http.ResponseWriter, statusCode int, msg string) {
-	if statusCode >= 500 {
-		log.Println("Responding with 5XX error", msg)
-	}
-
-	type errResponse struct {
-		Error string `json:"error"`
-	}
-
-	RespondWithJSON(w, statusCode, errResponse{
-		Error: msg,
-	})
-}
-
 func RespondWithJSON(w http.ResponseWriter, statusCode int, payload any) {
 	data, err := json.Marshal(payload)
 	if err != nil {
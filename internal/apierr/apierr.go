@@ -0,0 +1,203 @@
+// Package apierr describes API failures and writes them as RFC 7807
+// problem details (application/problem+json).
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Kind is the class of a failure. It decides the status code and the
+// stable problem type clients can rely on.
+type Kind string
+
+const (
+	BadRequest           Kind = "bad-request"
+	Validation           Kind = "validation-error"
+	NotFound             Kind = "not-found"
+	Conflict             Kind = "conflict"
+	PreconditionFailed   Kind = "precondition-failed"
+	Unprocessable        Kind = "unprocessable-entity"
+	PreconditionRequired Kind = "precondition-required"
+	Upstream             Kind = "upstream-failure"
+	Unavailable          Kind = "service-unavailable"
+	Internal             Kind = "internal-error"
+)
+
+type kindInfo struct {
+	status int
+	title  string
+}
+
+var kinds = map[Kind]kindInfo{
+	BadRequest:           {http.StatusBadRequest, "Bad request"},
+	Validation:           {http.StatusBadRequest, "Validation failed"},
+	NotFound:             {http.StatusNotFound, "Resource not found"},
+	Conflict:             {http.StatusConflict, "Conflict"},
+	PreconditionFailed:   {http.StatusPreconditionFailed, "Precondition failed"},
+	Unprocessable:        {http.StatusUnprocessableEntity, "Unprocessable entity"},
+	PreconditionRequired: {http.StatusPreconditionRequired, "Precondition required"},
+	Upstream:             {http.StatusBadGateway, "Upstream failure"},
+	Unavailable:          {http.StatusServiceUnavailable, "Service unavailable"},
+	Internal:             {http.StatusInternalServerError, "Internal server error"},
+}
+
+// TypeURI returns the problem type of kind.
+func TypeURI(kind Kind) string {
+	return "/problems/" + string(kind)
+}
+
+// FieldError points at an invalid request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a failure with a message for the client. Err, if set, is the
+// underlying cause and is never shown to the client.
+type Error struct {
+	Kind   Kind
+	Detail string
+	Fields []FieldError
+	// Extensions are added as extra members of the problem object.
+	Extensions map[string]any
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With adds an extension member to the problem.
+func (e *Error) With(name string, value any) *Error {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]any)
+	}
+	e.Extensions[name] = value
+	return e
+}
+
+func New(kind Kind, detail string) *Error {
+	return &Error{Kind: kind, Detail: detail}
+}
+
+func Wrap(kind Kind, detail string, err error) *Error {
+	return &Error{Kind: kind, Detail: detail, Err: err}
+}
+
+func NewBadRequest(detail string) *Error {
+	return New(BadRequest, detail)
+}
+
+func NewNotFound(detail string) *Error {
+	return New(NotFound, detail)
+}
+
+func NewConflict(detail string) *Error {
+	return New(Conflict, detail)
+}
+
+func NewInternal(detail string, err error) *Error {
+	return Wrap(Internal, detail, err)
+}
+
+// NewUnprocessable reports a well-formed request that cannot be applied,
+// e.g. because its fields refer to missing resources.
+func NewUnprocessable(detail string, fields ...FieldError) *Error {
+	return &Error{Kind: Unprocessable, Detail: detail, Fields: fields}
+}
+
+// NewValidation reports one or more invalid fields.
+func NewValidation(fields ...FieldError) *Error {
+	return &Error{Kind: Validation, Detail: "The request has invalid fields", Fields: fields}
+}
+
+// Problem is the RFC 7807 representation of an Error.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	// Extensions are marshaled as extra members next to the standard ones.
+	Extensions map[string]any `json:"-"`
+}
+
+// ProblemFor describes err as a problem. Errors other than *Error are
+// treated as internal and their text is not disclosed. The members that
+// depend on the request, Instance and RequestID, are left to the caller.
+func ProblemFor(err error) Problem {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = NewInternal("Internal server error", err)
+	}
+
+	info, ok := kinds[apiErr.Kind]
+	if !ok {
+		info = kinds[Internal]
+	}
+
+	return Problem{
+		Type:       TypeURI(apiErr.Kind),
+		Title:      info.title,
+		Status:     info.status,
+		Detail:     apiErr.Detail,
+		Errors:     apiErr.Fields,
+		Extensions: apiErr.Extensions,
+	}
+}
+
+// Write responds with err as a problem.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	problem := ProblemFor(err)
+	if problem.Status >= 500 {
+		log.Println("Responding with 5XX error", err)
+	}
+	problem.Instance = r.URL.Path
+	problem.RequestID = middleware.GetReqID(r.Context())
+
+	data, err := json.Marshal(problem)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	w.Write(data)
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type standard Problem
+	data, err := json.Marshal(standard(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	members := make(map[string]any, len(p.Extensions)+7)
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	var standardMembers map[string]any
+	if err := json.Unmarshal(data, &standardMembers); err != nil {
+		return nil, err
+	}
+	// Standard members win over extensions of the same name.
+	for name, value := range standardMembers {
+		members[name] = value
+	}
+	return json.Marshal(members)
+}
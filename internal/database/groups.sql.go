@@ -9,6 +9,40 @@ import (
 	"context"
 )
 
+const countSongsByGroupID = `-- name: CountSongsByGroupID :one
+SELECT COUNT(*) FROM songs WHERE group_id = $1
+`
+
+func (q *Queries) CountSongsByGroupID(ctx context.Context, groupID int32) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSongsByGroupID, groupID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteGroup = `-- name: DeleteGroup :execrows
+DELETE FROM groups WHERE id = $1
+`
+
+func (q *Queries) DeleteGroup(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteGroup, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getGroupByID = `-- name: GetGroupByID :one
+SELECT id, group_name FROM groups WHERE id = $1
+`
+
+func (q *Queries) GetGroupByID(ctx context.Context, id int32) (Group, error) {
+	row := q.db.QueryRowContext(ctx, getGroupByID, id)
+	var i Group
+	err := row.Scan(&i.ID, &i.GroupName)
+	return i, err
+}
+
 const getGroupIDByGroupName = `-- name: GetGroupIDByGroupName :one
 SELECT id FROM groups WHERE group_name = $1
 `
@@ -19,3 +53,80 @@ func (q *Queries) GetGroupIDByGroupName(ctx context.Context, groupName string) (
 	err := row.Scan(&id)
 	return id, err
 }
+
+const insertGroup = `-- name: InsertGroup :one
+INSERT INTO groups (group_name) VALUES ($1) RETURNING id, group_name
+`
+
+func (q *Queries) InsertGroup(ctx context.Context, groupName string) (Group, error) {
+	row := q.db.QueryRowContext(ctx, insertGroup, groupName)
+	var i Group
+	err := row.Scan(&i.ID, &i.GroupName)
+	return i, err
+}
+
+const listGroups = `-- name: ListGroups :many
+SELECT id, group_name FROM groups ORDER BY group_name LIMIT $1 OFFSET $2
+`
+
+type ListGroupsParams struct {
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) ListGroups(ctx context.Context, arg ListGroupsParams) ([]Group, error) {
+	rows, err := q.db.QueryContext(ctx, listGroups, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Group
+	for rows.Next() {
+		var i Group
+		if err := rows.Scan(&i.ID, &i.GroupName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateGroupName = `-- name: UpdateGroupName :one
+UPDATE groups SET group_name = $2 WHERE id = $1 RETURNING id, group_name
+`
+
+type UpdateGroupNameParams struct {
+	ID        int32
+	GroupName string
+}
+
+func (q *Queries) UpdateGroupName(ctx context.Context, arg UpdateGroupNameParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, updateGroupName, arg.ID, arg.GroupName)
+	var i Group
+	err := row.Scan(&i.ID, &i.GroupName)
+	return i, err
+}
+
+const upsertGroup = `-- name: UpsertGroup :one
+INSERT INTO groups (group_name) VALUES ($1)
+ON CONFLICT (group_name) DO UPDATE SET group_name = EXCLUDED.group_name
+RETURNING id, (xmax = 0) AS inserted
+`
+
+type UpsertGroupRow struct {
+	ID       int32
+	Inserted bool
+}
+
+func (q *Queries) UpsertGroup(ctx context.Context, groupName string) (UpsertGroupRow, error) {
+	row := q.db.QueryRowContext(ctx, upsertGroup, groupName)
+	var i UpsertGroupRow
+	err := row.Scan(&i.ID, &i.Inserted)
+	return i, err
+}
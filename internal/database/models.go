@@ -6,18 +6,45 @@ package database
 
 import (
 	"database/sql"
+	"time"
 )
 
+type EnrichmentJob struct {
+	ID          int32
+	SongID      int32
+	Status      string
+	Attempts    int32
+	LastError   sql.NullString
+	RunAfter    time.Time
+	LeasedUntil sql.NullTime
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
 type Group struct {
 	ID        int32
 	GroupName string
 }
 
 type Song struct {
-	ID          int32
-	SongName    string
-	ReleaseDate sql.NullTime
-	Text        sql.NullString
-	Link        sql.NullString
-	GroupID     int32
+	ID               int32
+	SongName         string
+	ReleaseDate      sql.NullTime
+	Text             sql.NullString
+	Link             sql.NullString
+	GroupID          int32
+	SearchVector     interface{}
+	EnrichmentStatus string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Version          int32
+}
+
+type SongInfoCache struct {
+	CacheKey    string
+	Found       bool
+	ReleaseDate string
+	Text        string
+	Link        string
+	ExpiresAt   time.Time
 }
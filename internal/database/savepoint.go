@@ -0,0 +1,26 @@
+package database
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+)
+
+// Savepoints let a transaction carry on after a failed statement, which
+// otherwise aborts it. They only work on Queries bound to a transaction
+// with WithTx.
+
+func (q *Queries) Savepoint(ctx context.Context, name string) error {
+	_, err := q.db.ExecContext(ctx, "SAVEPOINT "+pq.QuoteIdentifier(name))
+	return err
+}
+
+func (q *Queries) RollbackToSavepoint(ctx context.Context, name string) error {
+	_, err := q.db.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+pq.QuoteIdentifier(name))
+	return err
+}
+
+func (q *Queries) ReleaseSavepoint(ctx context.Context, name string) error {
+	_, err := q.db.ExecContext(ctx, "RELEASE SAVEPOINT "+pq.QuoteIdentifier(name))
+	return err
+}
@@ -8,19 +8,54 @@ package database
 import (
 	"context"
 	"database/sql"
+
+	"github.com/lib/pq"
 )
 
-const deleteSong = `-- name: DeleteSong :exec
-DELETE FROM songs WHERE id = $1
+const deleteSong = `-- name: DeleteSong :execrows
+DELETE FROM songs
+WHERE id = $1
+  AND ($2::int[] IS NULL OR version = ANY($2::int[]))
 `
 
-func (q *Queries) DeleteSong(ctx context.Context, id int32) error {
-	_, err := q.db.ExecContext(ctx, deleteSong, id)
-	return err
+type DeleteSongParams struct {
+	ID       int32
+	Versions []int32
+}
+
+func (q *Queries) DeleteSong(ctx context.Context, arg DeleteSongParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteSong, arg.ID, pq.Array(arg.Versions))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteSongsByGroupID = `-- name: DeleteSongsByGroupID :execrows
+DELETE FROM songs WHERE group_id = $1
+`
+
+func (q *Queries) DeleteSongsByGroupID(ctx context.Context, groupID int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteSongsByGroupID, groupID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getSongVersion = `-- name: GetSongVersion :one
+SELECT version FROM songs WHERE id = $1
+`
+
+func (q *Queries) GetSongVersion(ctx context.Context, id int32) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getSongVersion, id)
+	var version int32
+	err := row.Scan(&version)
+	return version, err
 }
 
 const getSongsFiltered = `-- name: GetSongsFiltered :many
-SELECT id, song_name, release_date, text, link, group_id
+SELECT id, song_name, release_date, text, link, group_id, search_vector, enrichment_status, created_at, updated_at, version
 FROM songs
 WHERE ($1 IS NULL OR group_id = $1)
   AND ($2 IS NULL OR song_name ILIKE '%' || $2 || '%')
@@ -65,6 +100,11 @@ func (q *Queries) GetSongsFiltered(ctx context.Context, arg GetSongsFilteredPara
 			&i.Text,
 			&i.Link,
 			&i.GroupID,
+			&i.SearchVector,
+			&i.EnrichmentStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -106,59 +146,94 @@ func (q *Queries) InsertSong(ctx context.Context, arg InsertSongParams) (int32,
 	return id, err
 }
 
-const updateSong = `-- name: UpdateSong :exec
-UPDATE songs SET group_id = $2, song_name = $3, text = $4, release_date = $5, link = $6 WHERE id = $1
+const lockSong = `-- name: LockSong :one
+SELECT version FROM songs WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) LockSong(ctx context.Context, id int32) (int32, error) {
+	row := q.db.QueryRowContext(ctx, lockSong, id)
+	var version int32
+	err := row.Scan(&version)
+	return version, err
+}
+
+const updateSong = `-- name: UpdateSong :one
+UPDATE songs
+SET group_id = $1, song_name = $2, text = $3, release_date = $4, link = $5
+WHERE id = $6
+  AND ($7::int[] IS NULL OR version = ANY($7::int[]))
+RETURNING version
 `
 
 type UpdateSongParams struct {
-	ID          int32
 	GroupID     int32
 	SongName    string
 	Text        sql.NullString
 	ReleaseDate sql.NullTime
 	Link        sql.NullString
+	ID          int32
+	Versions    []int32
 }
 
-func (q *Queries) UpdateSong(ctx context.Context, arg UpdateSongParams) error {
-	_, err := q.db.ExecContext(ctx, updateSong,
-		arg.ID,
+// versions, unless NULL, lists the versions the song must have (If-Match).
+func (q *Queries) UpdateSong(ctx context.Context, arg UpdateSongParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, updateSong,
 		arg.GroupID,
 		arg.SongName,
 		arg.Text,
 		arg.ReleaseDate,
 		arg.Link,
+		arg.ID,
+		pq.Array(arg.Versions),
 	)
-	return err
+	var version int32
+	err := row.Scan(&version)
+	return version, err
 }
 
-const updateSongPartial = `-- name: UpdateSongPartial :exec
+const updateSongPartial = `-- name: UpdateSongPartial :one
 UPDATE songs
 SET
-    group_id = COALESCE($2, group_id),
-    song_name = COALESCE($3, song_name),
-    text = COALESCE($4, text),
-    release_date = COALESCE($5, release_date),
-    link = COALESCE($6, link)
-WHERE id = $1
+    group_id = COALESCE($1, group_id),
+    song_name = COALESCE($2, song_name),
+    text = CASE WHEN $3::boolean THEN $4::text ELSE text END,
+    release_date = CASE WHEN $5::boolean THEN $6::date ELSE release_date END,
+    link = CASE WHEN $7::boolean THEN $8::text ELSE link END
+WHERE id = $9
+  AND ($10::int[] IS NULL OR version = ANY($10::int[]))
+RETURNING version
 `
 
 type UpdateSongPartialParams struct {
-	ID          int32
-	GroupID     int32
-	SongName    string
-	Text        sql.NullString
-	ReleaseDate sql.NullTime
-	Link        sql.NullString
+	GroupID        sql.NullInt32
+	SongName       sql.NullString
+	SetText        bool
+	Text           sql.NullString
+	SetReleaseDate bool
+	ReleaseDate    sql.NullTime
+	SetLink        bool
+	Link           sql.NullString
+	ID             int32
+	Versions       []int32
 }
 
-func (q *Queries) UpdateSongPartial(ctx context.Context, arg UpdateSongPartialParams) error {
-	_, err := q.db.ExecContext(ctx, updateSongPartial,
-		arg.ID,
+// The set_ flags tell a NULL that clears a nullable column from an
+// omitted one, which keeps the current value. versions works as in
+// UpdateSong.
+func (q *Queries) UpdateSongPartial(ctx context.Context, arg UpdateSongPartialParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, updateSongPartial,
 		arg.GroupID,
 		arg.SongName,
+		arg.SetText,
 		arg.Text,
+		arg.SetReleaseDate,
 		arg.ReleaseDate,
+		arg.SetLink,
 		arg.Link,
+		arg.ID,
+		pq.Array(arg.Versions),
 	)
-	return err
+	var version int32
+	err := row.Scan(&version)
+	return version, err
 }
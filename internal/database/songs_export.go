@@ -0,0 +1,58 @@
+package database
+
+// Exports read the songs through a server-side cursor, so only one batch
+// is held in memory at a time. Cursors live in a transaction, so the
+// Queries must be bound to one with WithTx.
+
+import (
+	"context"
+	"strconv"
+)
+
+const exportSongsCursor = "export_songs"
+
+type ExportSongsParams struct {
+	Filter SongFilter
+	// WithText fetches the lyrics, which are left NULL otherwise.
+	WithText bool
+	// Sort defaults to the newest release first, like listings.
+	Sort []SongSort
+	// BatchSize is the number of rows fetched from the cursor at once.
+	BatchSize int
+}
+
+// ExportSongs passes the songs matching the filter to fn in batches, in
+// listing order. It stops at the first error fn returns.
+func (q *Queries) ExportSongs(ctx context.Context, arg ExportSongsParams, fn func([]ListSongsPageRow) error) error {
+	keys := SongSortKeys(defaultSongSort(arg.Sort))
+	b := buildSongFilter(arg.Filter)
+	query := songsSelect(arg.WithText) + b.where() + "\nORDER BY " + orderBy(keys, false)
+
+	if _, err := q.db.ExecContext(ctx, "DECLARE "+exportSongsCursor+" NO SCROLL CURSOR FOR\n"+query, b.args...); err != nil {
+		return err
+	}
+
+	fetch := "FETCH FORWARD " + strconv.Itoa(arg.BatchSize) + " FROM " + exportSongsCursor
+	for {
+		rows, err := q.db.QueryContext(ctx, fetch)
+		if err != nil {
+			return err
+		}
+		items, err := scanListSongsPageRows(rows)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+		if err := fn(items); err != nil {
+			return err
+		}
+		if len(items) < arg.BatchSize {
+			break
+		}
+	}
+
+	_, err := q.db.ExecContext(ctx, "CLOSE "+exportSongsCursor)
+	return err
+}
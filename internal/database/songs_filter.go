@@ -0,0 +1,433 @@
+package database
+
+// The song listing filters are combined at runtime, which a fixed sqlc
+// statement cannot express. The queries below are assembled from fixed SQL
+// fragments; every caller supplied value is passed as a parameter.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type MatchMode string
+
+const (
+	MatchContains MatchMode = "contains"
+	MatchPrefix   MatchMode = "prefix"
+	MatchExact    MatchMode = "exact"
+)
+
+// SongFilter selects songs for a listing. Zero values do not filter. All
+// name and text matches ignore case.
+type SongFilter struct {
+	// GroupNames matches songs of any of the named groups.
+	GroupNames []string
+	GroupMatch MatchMode
+	GroupIDs   []int32
+	SongName   string
+	SongMatch  MatchMode
+	// Text matches songs whose lyrics contain it.
+	Text         string
+	ReleasedFrom sql.NullTime
+	ReleasedTo   sql.NullTime
+	HasLink      sql.NullBool
+	HasText      sql.NullBool
+}
+
+// IsEmpty reports whether the filter selects every song.
+func (f SongFilter) IsEmpty() bool {
+	return len(f.GroupNames) == 0 && len(f.GroupIDs) == 0 && f.SongName == "" && f.Text == "" &&
+		!f.ReleasedFrom.Valid && !f.ReleasedTo.Valid && !f.HasLink.Valid && !f.HasText.Valid
+}
+
+// SongSort is one sort key of a listing.
+type SongSort struct {
+	Field string
+	Desc  bool
+}
+
+// SongSortFields are the fields a listing can be sorted by.
+var SongSortFields = []string{"song_name", "group_name", "release_date", "id", "created_at", "updated_at"}
+
+type songSortColumn struct {
+	expr     string
+	cast     string
+	nullable bool
+}
+
+var songSortColumns = map[string]songSortColumn{
+	"song_name":    {expr: "s.song_name", cast: "text"},
+	"group_name":   {expr: "g.group_name", cast: "text"},
+	"release_date": {expr: "s.release_date", cast: "date", nullable: true},
+	"id":           {expr: "s.id", cast: "int"},
+	"created_at":   {expr: "s.created_at", cast: "timestamptz"},
+	"updated_at":   {expr: "s.updated_at", cast: "timestamptz"},
+}
+
+// SongSortKeys completes sort with the id as the final tie-breaker, so the
+// order is total. The tie-breaker follows the direction of the first key.
+func SongSortKeys(sort []SongSort) []SongSort {
+	keys := make([]SongSort, 0, len(sort)+1)
+	for _, key := range sort {
+		keys = append(keys, key)
+		if key.Field == "id" {
+			return keys
+		}
+	}
+	desc := len(sort) > 0 && sort[0].Desc
+	return append(keys, SongSort{Field: "id", Desc: desc})
+}
+
+// SongKey is the position of a song in a listing: its values of the sort
+// keys returned by SongSortKeys, in text form.
+type SongKey []sql.NullString
+
+// ValidSongKey reports whether key holds a well-formed value for each of
+// the sort keys.
+func ValidSongKey(keys []SongSort, key SongKey) bool {
+	if len(key) != len(keys) {
+		return false
+	}
+	for i, sortKey := range keys {
+		column := songSortColumns[sortKey.Field]
+		if !key[i].Valid {
+			if !column.nullable {
+				return false
+			}
+			continue
+		}
+		var err error
+		switch column.cast {
+		case "date":
+			_, err = time.Parse("2006-01-02", key[i].String)
+		case "timestamptz":
+			_, err = time.Parse(time.RFC3339Nano, key[i].String)
+		case "int":
+			_, err = strconv.ParseInt(key[i].String, 10, 32)
+		}
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+type ListSongsPageParams struct {
+	Filter SongFilter
+	// WithText fetches the lyrics, which are left NULL otherwise.
+	WithText bool
+	// Sort defaults to the newest release first. NULL values sort last in
+	// either direction.
+	Sort   []SongSort
+	Limit  int32
+	Offset int32
+	// After or Before switch to keyset pagination: the page starts right
+	// after, or ends right before, the given song. Offset is ignored then.
+	After  SongKey
+	Before SongKey
+}
+
+type ListSongsPageRow struct {
+	ID               int32
+	SongName         string
+	ReleaseDate      sql.NullTime
+	Text             sql.NullString
+	Link             sql.NullString
+	GroupID          int32
+	EnrichmentStatus string
+	GroupName        string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Version          int32
+}
+
+// Key returns the position of the row for the given sort keys.
+func (r ListSongsPageRow) Key(keys []SongSort) SongKey {
+	key := make(SongKey, 0, len(keys))
+	for _, sortKey := range keys {
+		var value sql.NullString
+		switch sortKey.Field {
+		case "song_name":
+			value = sql.NullString{String: r.SongName, Valid: true}
+		case "group_name":
+			value = sql.NullString{String: r.GroupName, Valid: true}
+		case "release_date":
+			if r.ReleaseDate.Valid {
+				value = sql.NullString{String: r.ReleaseDate.Time.Format("2006-01-02"), Valid: true}
+			}
+		case "id":
+			value = sql.NullString{String: strconv.Itoa(int(r.ID)), Valid: true}
+		case "created_at":
+			value = sql.NullString{String: r.CreatedAt.Format(time.RFC3339Nano), Valid: true}
+		case "updated_at":
+			value = sql.NullString{String: r.UpdatedAt.Format(time.RFC3339Nano), Valid: true}
+		}
+		key = append(key, value)
+	}
+	return key
+}
+
+type songQuery struct {
+	conds []string
+	args  []interface{}
+}
+
+func (b *songQuery) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *songQuery) where() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return "\nWHERE " + strings.Join(b.conds, "\n  AND ")
+}
+
+// escapeLike makes LIKE wildcards in value match literally.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func (b *songQuery) match(column, value string, mode MatchMode) string {
+	pattern := escapeLike(value)
+	switch mode {
+	case MatchExact:
+	case MatchPrefix:
+		pattern += "%"
+	default:
+		pattern = "%" + pattern + "%"
+	}
+	return column + " ILIKE " + b.arg(pattern)
+}
+
+func presence(column string, present bool) string {
+	if present {
+		return "(" + column + " IS NOT NULL AND " + column + " <> '')"
+	}
+	return "(" + column + " IS NULL OR " + column + " = '')"
+}
+
+func buildSongFilter(f SongFilter) *songQuery {
+	b := &songQuery{}
+
+	if len(f.GroupNames) > 0 {
+		matches := make([]string, 0, len(f.GroupNames))
+		for _, name := range f.GroupNames {
+			matches = append(matches, b.match("g.group_name", name, f.GroupMatch))
+		}
+		b.conds = append(b.conds, "("+strings.Join(matches, " OR ")+")")
+	}
+	if len(f.GroupIDs) > 0 {
+		b.conds = append(b.conds, "s.group_id = ANY("+b.arg(pq.Array(f.GroupIDs))+"::int[])")
+	}
+	if f.SongName != "" {
+		b.conds = append(b.conds, b.match("s.song_name", f.SongName, f.SongMatch))
+	}
+	if f.Text != "" {
+		b.conds = append(b.conds, b.match("s.text", f.Text, MatchContains))
+	}
+	if f.ReleasedFrom.Valid {
+		b.conds = append(b.conds, "s.release_date >= "+b.arg(f.ReleasedFrom.Time)+"::date")
+	}
+	if f.ReleasedTo.Valid {
+		b.conds = append(b.conds, "s.release_date <= "+b.arg(f.ReleasedTo.Time)+"::date")
+	}
+	if f.HasLink.Valid {
+		b.conds = append(b.conds, presence("s.link", f.HasLink.Bool))
+	}
+	if f.HasText.Valid {
+		b.conds = append(b.conds, presence("s.text", f.HasText.Bool))
+	}
+
+	return b
+}
+
+// keyset restricts the listing to the songs after the key in the order
+// of keys, or before it when before is set.
+func (b *songQuery) keyset(keys []SongSort, key SongKey, before bool) {
+	var disjuncts, equal []string
+	for i, sortKey := range keys {
+		column := songSortColumns[sortKey.Field]
+		value := key[i]
+
+		var placeholder, step string
+		if value.Valid {
+			placeholder = b.arg(value.String) + "::" + column.cast
+			op := ">"
+			if sortKey.Desc != before {
+				op = "<"
+			}
+			step = column.expr + " " + op + " " + placeholder
+			if column.nullable && !before {
+				step = "(" + step + " OR " + column.expr + " IS NULL)"
+			}
+		} else if before {
+			step = column.expr + " IS NOT NULL"
+		}
+
+		// NULLs sort last, nothing comes after a NULL key.
+		if step != "" {
+			disjuncts = append(disjuncts, strings.Join(append(equal[:len(equal):len(equal)], step), " AND "))
+		}
+
+		if value.Valid {
+			equal = append(equal, column.expr+" = "+placeholder)
+		} else {
+			equal = append(equal, column.expr+" IS NULL")
+		}
+	}
+
+	if len(disjuncts) == 0 {
+		b.conds = append(b.conds, "FALSE")
+		return
+	}
+	b.conds = append(b.conds, "("+strings.Join(disjuncts, "\n    OR ")+")")
+}
+
+// orderBy renders the order of keys, reversed for walking backwards.
+func orderBy(keys []SongSort, reverse bool) string {
+	terms := make([]string, 0, len(keys))
+	for _, sortKey := range keys {
+		dir, nulls := "ASC", "LAST"
+		if sortKey.Desc != reverse {
+			dir = "DESC"
+		}
+		if reverse {
+			nulls = "FIRST"
+		}
+		terms = append(terms, songSortColumns[sortKey.Field].expr+" "+dir+" NULLS "+nulls)
+	}
+	return strings.Join(terms, ", ")
+}
+
+const listSongsPageSelect = `SELECT s.id, s.song_name, s.release_date, %s, s.link, s.group_id, s.enrichment_status, g.group_name, s.created_at, s.updated_at, s.version
+FROM songs s
+JOIN groups g ON s.group_id = g.id`
+
+// ListSongsPage returns the songs matching the filter in listing order,
+// also for pages taken before a key.
+func (q *Queries) ListSongsPage(ctx context.Context, arg ListSongsPageParams) ([]ListSongsPageRow, error) {
+	keys := SongSortKeys(defaultSongSort(arg.Sort))
+
+	b := buildSongFilter(arg.Filter)
+	backward := len(arg.Before) > 0
+	switch {
+	case backward:
+		// Walk backwards from the key; the rows are reversed below.
+		b.keyset(keys, arg.Before, true)
+	case len(arg.After) > 0:
+		b.keyset(keys, arg.After, false)
+	}
+
+	query := songsSelect(arg.WithText) + b.where() + "\nORDER BY " + orderBy(keys, backward) + "\nLIMIT " + b.arg(arg.Limit)
+	if len(arg.Before) == 0 && len(arg.After) == 0 {
+		query += " OFFSET " + b.arg(arg.Offset)
+	}
+
+	rows, err := q.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	items, err := scanListSongsPageRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	return items, nil
+}
+
+func defaultSongSort(sort []SongSort) []SongSort {
+	if len(sort) == 0 {
+		return []SongSort{{Field: "release_date", Desc: true}}
+	}
+	return sort
+}
+
+func songsSelect(withText bool) string {
+	text := "NULL::text"
+	if withText {
+		text = "s.text"
+	}
+	return fmt.Sprintf(listSongsPageSelect, text)
+}
+
+func scanListSongsPageRows(rows *sql.Rows) ([]ListSongsPageRow, error) {
+	defer rows.Close()
+	var items []ListSongsPageRow
+	for rows.Next() {
+		var i ListSongsPageRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SongName,
+			&i.ReleaseDate,
+			&i.Text,
+			&i.Link,
+			&i.GroupID,
+			&i.EnrichmentStatus,
+			&i.GroupName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// CountSongs counts the songs matching the filter.
+func (q *Queries) CountSongs(ctx context.Context, filter SongFilter) (int64, error) {
+	b := buildSongFilter(filter)
+	query := "SELECT COUNT(*)\nFROM songs s\nJOIN groups g ON s.group_id = g.id" + b.where()
+
+	var total int64
+	err := q.db.QueryRowContext(ctx, query, b.args...).Scan(&total)
+	return total, err
+}
+
+// LockSongIDs returns the ids of the songs matching the filter in id order
+// and locks the songs until the end of the surrounding transaction.
+func (q *Queries) LockSongIDs(ctx context.Context, filter SongFilter) ([]int32, error) {
+	b := buildSongFilter(filter)
+	query := "SELECT s.id\nFROM songs s\nJOIN groups g ON s.group_id = g.id" + b.where() + "\nORDER BY s.id\nFOR UPDATE OF s"
+
+	rows, err := q.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,306 @@
+package database
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"Muse", "Muse"},
+		{"100%", `100\%`},
+		{"snake_case", `snake\_case`},
+		{`back\slash`, `back\\slash`},
+		{`\%_`, `\\\%\_`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeLike(tt.value); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBuildSongFilter(t *testing.T) {
+	from := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		filter    SongFilter
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:      "contains by default",
+			filter:    SongFilter{SongName: "love"},
+			wantWhere: "\nWHERE s.song_name ILIKE $1",
+			wantArgs:  []interface{}{"%love%"},
+		},
+		{
+			name:      "prefix",
+			filter:    SongFilter{SongName: "love", SongMatch: MatchPrefix},
+			wantWhere: "\nWHERE s.song_name ILIKE $1",
+			wantArgs:  []interface{}{"love%"},
+		},
+		{
+			name:      "exact still escapes wildcards",
+			filter:    SongFilter{SongName: "100%_pure", SongMatch: MatchExact},
+			wantWhere: "\nWHERE s.song_name ILIKE $1",
+			wantArgs:  []interface{}{`100\%\_pure`},
+		},
+		{
+			name:      "any of several groups",
+			filter:    SongFilter{GroupNames: []string{"Muse", "Queen"}, GroupMatch: MatchExact},
+			wantWhere: "\nWHERE (g.group_name ILIKE $1 OR g.group_name ILIKE $2)",
+			wantArgs:  []interface{}{"Muse", "Queen"},
+		},
+		{
+			name:      "group ids",
+			filter:    SongFilter{GroupIDs: []int32{1, 2}},
+			wantWhere: "\nWHERE s.group_id = ANY($1::int[])",
+			wantArgs:  []interface{}{pq.Array([]int32{1, 2})},
+		},
+		{
+			name:      "text always matches a substring",
+			filter:    SongFilter{Text: "baby"},
+			wantWhere: "\nWHERE s.text ILIKE $1",
+			wantArgs:  []interface{}{"%baby%"},
+		},
+		{
+			name: "date range",
+			filter: SongFilter{
+				ReleasedFrom: sql.NullTime{Time: from, Valid: true},
+				ReleasedTo:   sql.NullTime{Time: to, Valid: true},
+			},
+			wantWhere: "\nWHERE s.release_date >= $1::date\n  AND s.release_date <= $2::date",
+			wantArgs:  []interface{}{from, to},
+		},
+		{
+			name:      "open ended date range",
+			filter:    SongFilter{ReleasedTo: sql.NullTime{Time: to, Valid: true}},
+			wantWhere: "\nWHERE s.release_date <= $1::date",
+			wantArgs:  []interface{}{to},
+		},
+		{
+			name:      "presence",
+			filter:    SongFilter{HasLink: sql.NullBool{Bool: true, Valid: true}, HasText: sql.NullBool{Valid: true}},
+			wantWhere: "\nWHERE (s.link IS NOT NULL AND s.link <> '')\n  AND (s.text IS NULL OR s.text = '')",
+		},
+		{
+			name: "combined",
+			filter: SongFilter{
+				GroupNames: []string{"Muse"},
+				GroupIDs:   []int32{7},
+				SongName:   "star",
+				SongMatch:  MatchPrefix,
+				Text:       "light",
+			},
+			wantWhere: "\nWHERE (g.group_name ILIKE $1)\n  AND s.group_id = ANY($2::int[])\n  AND s.song_name ILIKE $3\n  AND s.text ILIKE $4",
+			wantArgs:  []interface{}{"%Muse%", pq.Array([]int32{7}), "star%", "%light%"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := buildSongFilter(tt.filter)
+			if got := b.where(); got != tt.wantWhere {
+				t.Errorf("where = %q, want %q", got, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", b.args, tt.wantArgs)
+			}
+			if tt.filter.IsEmpty() != (tt.wantWhere == "") {
+				t.Errorf("IsEmpty = %v for where %q", tt.filter.IsEmpty(), tt.wantWhere)
+			}
+		})
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	// -release_date,song_name completed with the id tie-breaker.
+	keys := SongSortKeys([]SongSort{{Field: "release_date", Desc: true}, {Field: "song_name"}})
+	dated := SongKey{{String: "2006-07-16", Valid: true}, {String: "Starlight", Valid: true}, {String: "3", Valid: true}}
+	undated := SongKey{{}, {String: "Starlight", Valid: true}, {String: "3", Valid: true}}
+
+	tests := []struct {
+		name      string
+		key       SongKey
+		before    bool
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			// Undated songs sort last, so they all come after a dated one.
+			name: "after a dated song",
+			key:  dated,
+			wantWhere: "\nWHERE ((s.release_date < $1::date OR s.release_date IS NULL)" +
+				"\n    OR s.release_date = $1::date AND s.song_name > $2::text" +
+				"\n    OR s.release_date = $1::date AND s.song_name = $2::text AND s.id < $3::int)",
+			wantArgs: []interface{}{"2006-07-16", "Starlight", "3"},
+		},
+		{
+			name: "after an undated song",
+			key:  undated,
+			wantWhere: "\nWHERE (s.release_date IS NULL AND s.song_name > $1::text" +
+				"\n    OR s.release_date IS NULL AND s.song_name = $1::text AND s.id < $2::int)",
+			wantArgs: []interface{}{"Starlight", "3"},
+		},
+		{
+			name:   "before a dated song",
+			key:    dated,
+			before: true,
+			wantWhere: "\nWHERE (s.release_date > $1::date" +
+				"\n    OR s.release_date = $1::date AND s.song_name < $2::text" +
+				"\n    OR s.release_date = $1::date AND s.song_name = $2::text AND s.id > $3::int)",
+			wantArgs: []interface{}{"2006-07-16", "Starlight", "3"},
+		},
+		{
+			// Every dated song comes before an undated one.
+			name:   "before an undated song",
+			key:    undated,
+			before: true,
+			wantWhere: "\nWHERE (s.release_date IS NOT NULL" +
+				"\n    OR s.release_date IS NULL AND s.song_name < $1::text" +
+				"\n    OR s.release_date IS NULL AND s.song_name = $1::text AND s.id > $2::int)",
+			wantArgs: []interface{}{"Starlight", "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &songQuery{}
+			b.keyset(keys, tt.key, tt.before)
+			if got := b.where(); got != tt.wantWhere {
+				t.Errorf("where = %q, want %q", got, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", b.args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestKeysetAfterFilter(t *testing.T) {
+	b := buildSongFilter(SongFilter{SongName: "star"})
+	b.keyset([]SongSort{{Field: "id"}}, SongKey{{String: "3", Valid: true}}, false)
+
+	want := "\nWHERE s.song_name ILIKE $1\n  AND (s.id > $2::int)"
+	if got := b.where(); got != want {
+		t.Errorf("where = %q, want %q", got, want)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	keys := SongSortKeys([]SongSort{{Field: "release_date", Desc: true}, {Field: "song_name"}})
+
+	tests := []struct {
+		reverse bool
+		want    string
+	}{
+		{false, "s.release_date DESC NULLS LAST, s.song_name ASC NULLS LAST, s.id DESC NULLS LAST"},
+		{true, "s.release_date ASC NULLS FIRST, s.song_name DESC NULLS FIRST, s.id ASC NULLS FIRST"},
+	}
+
+	for _, tt := range tests {
+		if got := orderBy(keys, tt.reverse); got != tt.want {
+			t.Errorf("orderBy(reverse=%v) = %q, want %q", tt.reverse, got, tt.want)
+		}
+	}
+}
+
+func TestSongSortKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		sort []SongSort
+		want []SongSort
+	}{
+		{
+			name: "no sort",
+			want: []SongSort{{Field: "id"}},
+		},
+		{
+			name: "tie-breaker follows the first key",
+			sort: []SongSort{{Field: "release_date", Desc: true}, {Field: "song_name"}},
+			want: []SongSort{{Field: "release_date", Desc: true}, {Field: "song_name"}, {Field: "id", Desc: true}},
+		},
+		{
+			name: "keys after the id are dropped",
+			sort: []SongSort{{Field: "id", Desc: true}, {Field: "song_name"}},
+			want: []SongSort{{Field: "id", Desc: true}},
+		},
+		{
+			name: "explicit id last",
+			sort: []SongSort{{Field: "group_name"}, {Field: "id", Desc: true}},
+			want: []SongSort{{Field: "group_name"}, {Field: "id", Desc: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SongSortKeys(tt.sort); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SongSortKeys = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidSongKey(t *testing.T) {
+	keys := []SongSort{{Field: "release_date"}, {Field: "created_at"}, {Field: "id"}}
+	valid := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+
+	tests := []struct {
+		name string
+		key  SongKey
+		want bool
+	}{
+		{"valid", SongKey{valid("2006-07-16"), valid("2024-01-01T10:00:00.123456Z"), valid("3")}, true},
+		{"null in a nullable key", SongKey{{}, valid("2024-01-01T10:00:00Z"), valid("3")}, true},
+		{"null in a key that is never null", SongKey{valid("2006-07-16"), {}, valid("3")}, false},
+		{"too short", SongKey{valid("2006-07-16"), valid("2024-01-01T10:00:00Z")}, false},
+		{"too long", SongKey{valid("2006-07-16"), valid("2024-01-01T10:00:00Z"), valid("3"), valid("4")}, false},
+		{"bad date", SongKey{valid("16.07.2006"), valid("2024-01-01T10:00:00Z"), valid("3")}, false},
+		{"bad timestamp", SongKey{valid("2006-07-16"), valid("yesterday"), valid("3")}, false},
+		{"id out of range", SongKey{valid("2006-07-16"), valid("2024-01-01T10:00:00Z"), valid("2147483648")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidSongKey(keys, tt.key); got != tt.want {
+				t.Errorf("ValidSongKey = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListSongsPageRowKey(t *testing.T) {
+	keys := SongSortKeys([]SongSort{{Field: "group_name"}, {Field: "release_date"}, {Field: "updated_at"}})
+	row := ListSongsPageRow{
+		ID:        3,
+		GroupName: "Muse",
+		UpdatedAt: time.Date(2024, 1, 1, 10, 0, 0, 123456000, time.UTC),
+	}
+
+	want := SongKey{
+		{String: "Muse", Valid: true},
+		{},
+		{String: "2024-01-01T10:00:00.123456Z", Valid: true},
+		{String: "3", Valid: true},
+	}
+	got := row.Key(keys)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Key = %+v, want %+v", got, want)
+	}
+	if !ValidSongKey(keys, got) {
+		t.Errorf("ValidSongKey rejected the key of a row: %+v", got)
+	}
+}
@@ -8,16 +8,72 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
-const getSongVersesWithPagination = `-- name: GetSongVersesWithPagination :one
-WITH verses AS (
-  SELECT unnest(string_to_array(text, E'\n\n')) AS verse
-  FROM songs
-  WHERE id = $1
-)
-SELECT verse
-FROM verses
+const countSongVerses = `-- name: CountSongVerses :one
+SELECT (
+  SELECT COUNT(*)
+  FROM unnest(string_to_array(s.text, E'\n\n')) AS v(verse)
+  WHERE btrim(v.verse) <> ''
+) AS total
+FROM songs s
+WHERE s.id = $1
+`
+
+func (q *Queries) CountSongVerses(ctx context.Context, id int32) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSongVerses, id)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const getSongByID = `-- name: GetSongByID :one
+SELECT s.id, s.song_name, s.release_date, s.text, s.link, s.group_id, s.enrichment_status, g.group_name, s.created_at, s.updated_at, s.version
+FROM songs s
+JOIN groups g ON s.group_id = g.id
+WHERE s.id = $1
+`
+
+type GetSongByIDRow struct {
+	ID               int32
+	SongName         string
+	ReleaseDate      sql.NullTime
+	Text             sql.NullString
+	Link             sql.NullString
+	GroupID          int32
+	EnrichmentStatus string
+	GroupName        string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Version          int32
+}
+
+func (q *Queries) GetSongByID(ctx context.Context, id int32) (GetSongByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getSongByID, id)
+	var i GetSongByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.SongName,
+		&i.ReleaseDate,
+		&i.Text,
+		&i.Link,
+		&i.GroupID,
+		&i.EnrichmentStatus,
+		&i.GroupName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const getSongVersesWithPagination = `-- name: GetSongVersesWithPagination :many
+SELECT v.verse::text AS verse
+FROM songs s,
+  unnest(string_to_array(s.text, E'\n\n')) WITH ORDINALITY AS v(verse, n)
+WHERE s.id = $1 AND btrim(v.verse) <> ''
+ORDER BY v.n
 LIMIT $2 OFFSET $3
 `
 
@@ -27,62 +83,79 @@ type GetSongVersesWithPaginationParams struct {
 	Offset int32
 }
 
-func (q *Queries) GetSongVersesWithPagination(ctx context.Context, arg GetSongVersesWithPaginationParams) (interface{}, error) {
-	row := q.db.QueryRowContext(ctx, getSongVersesWithPagination, arg.ID, arg.Limit, arg.Offset)
-	var verse interface{}
-	err := row.Scan(&verse)
-	return verse, err
+func (q *Queries) GetSongVersesWithPagination(ctx context.Context, arg GetSongVersesWithPaginationParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getSongVersesWithPagination, arg.ID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var verse string
+		if err := rows.Scan(&verse); err != nil {
+			return nil, err
+		}
+		items = append(items, verse)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getSongWithFiltersAndPagination = `-- name: GetSongWithFiltersAndPagination :many
-SELECT s.id, g.group_name, s.song_name, s.release_date, s.link
+const listSongsForResync = `-- name: ListSongsForResync :many
+SELECT s.id, s.song_name, g.group_name, s.release_date, s.text, s.link, s.version
 FROM songs s
 JOIN groups g ON s.group_id = g.id
-WHERE 
-  (g.group_name ILIKE '%' || $1 || '%' OR $1 IS NULL) AND
-  (s.song_name ILIKE '%' || $2 || '%' OR $2 IS NULL) AND
-  (s.release_date = $3 OR $3 IS NULL)
-ORDER BY s.release_date DESC
-LIMIT $4 OFFSET $5
+WHERE ($1::int IS NULL OR s.id = $1)
+  AND ($2::int IS NULL OR s.group_id = $2)
+  AND s.id > $3
+ORDER BY s.id
+LIMIT $4
 `
 
-type GetSongWithFiltersAndPaginationParams struct {
-	Column1     sql.NullString
-	Column2     sql.NullString
-	ReleaseDate sql.NullTime
-	Limit       int32
-	Offset      int32
+type ListSongsForResyncParams struct {
+	SongID    sql.NullInt32
+	GroupID   sql.NullInt32
+	AfterID   int32
+	BatchSize int32
 }
 
-type GetSongWithFiltersAndPaginationRow struct {
+type ListSongsForResyncRow struct {
 	ID          int32
-	GroupName   string
 	SongName    string
+	GroupName   string
 	ReleaseDate sql.NullTime
+	Text        sql.NullString
 	Link        sql.NullString
+	Version     int32
 }
 
-func (q *Queries) GetSongWithFiltersAndPagination(ctx context.Context, arg GetSongWithFiltersAndPaginationParams) ([]GetSongWithFiltersAndPaginationRow, error) {
-	rows, err := q.db.QueryContext(ctx, getSongWithFiltersAndPagination,
-		arg.Column1,
-		arg.Column2,
-		arg.ReleaseDate,
-		arg.Limit,
-		arg.Offset,
+func (q *Queries) ListSongsForResync(ctx context.Context, arg ListSongsForResyncParams) ([]ListSongsForResyncRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSongsForResync,
+		arg.SongID,
+		arg.GroupID,
+		arg.AfterID,
+		arg.BatchSize,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetSongWithFiltersAndPaginationRow
+	var items []ListSongsForResyncRow
 	for rows.Next() {
-		var i GetSongWithFiltersAndPaginationRow
+		var i ListSongsForResyncRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.GroupName,
 			&i.SongName,
+			&i.GroupName,
 			&i.ReleaseDate,
+			&i.Text,
 			&i.Link,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
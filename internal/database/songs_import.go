@@ -0,0 +1,176 @@
+package database
+
+// Song imports stage the rows in a temporary table with COPY and move them
+// to songs with a few set-based statements. The staging table is dropped
+// on commit, so the Queries must be bound to a transaction with WithTx.
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+const createImportStaging = `CREATE TEMP TABLE import_songs (
+    row_number INTEGER NOT NULL,
+    group_name TEXT NOT NULL,
+    song_name TEXT NOT NULL,
+    release_date DATE,
+    text TEXT,
+    link TEXT,
+    skip_reason TEXT
+) ON COMMIT DROP`
+
+type ImportSongRow struct {
+	// RowNumber identifies the row in the source for the report.
+	RowNumber   int32
+	GroupName   string
+	SongName    string
+	ReleaseDate sql.NullTime
+	Text        sql.NullString
+	Link        sql.NullString
+}
+
+// ImportCopy streams rows into the staging table.
+type ImportCopy struct {
+	stmt *sql.Stmt
+}
+
+// BeginImport creates the staging table and starts copying into it. No
+// other statement can run on the transaction until the copy is closed.
+func (q *Queries) BeginImport(ctx context.Context) (*ImportCopy, error) {
+	if _, err := q.db.ExecContext(ctx, createImportStaging); err != nil {
+		return nil, err
+	}
+	stmt, err := q.db.PrepareContext(ctx, pq.CopyIn("import_songs",
+		"row_number", "group_name", "song_name", "release_date", "text", "link"))
+	if err != nil {
+		return nil, err
+	}
+	return &ImportCopy{stmt: stmt}, nil
+}
+
+func (c *ImportCopy) Add(ctx context.Context, row ImportSongRow) error {
+	_, err := c.stmt.ExecContext(ctx,
+		row.RowNumber,
+		row.GroupName,
+		row.SongName,
+		row.ReleaseDate,
+		row.Text,
+		row.Link,
+	)
+	return err
+}
+
+// Close flushes the copied rows.
+func (c *ImportCopy) Close(ctx context.Context) error {
+	if _, err := c.stmt.ExecContext(ctx); err != nil {
+		c.stmt.Close()
+		return err
+	}
+	return c.stmt.Close()
+}
+
+const upsertImportGroups = `INSERT INTO groups (group_name)
+SELECT DISTINCT group_name FROM import_songs
+ON CONFLICT (group_name) DO NOTHING`
+
+// Within the file only the first row of a group and song name is kept.
+const skipImportDuplicates = `UPDATE import_songs i
+SET skip_reason = 'duplicate of row ' || first.row_number
+FROM (
+    SELECT group_name, song_name, MIN(row_number) AS row_number
+    FROM import_songs
+    GROUP BY group_name, song_name
+) first
+WHERE i.group_name = first.group_name
+  AND i.song_name = first.song_name
+  AND i.row_number > first.row_number`
+
+const skipImportExisting = `UPDATE import_songs i
+SET skip_reason = 'song already exists'
+FROM groups g
+WHERE i.skip_reason IS NULL
+  AND g.group_name = i.group_name
+  AND EXISTS (SELECT 1 FROM songs s WHERE s.group_id = g.id AND s.song_name = i.song_name)`
+
+// Songs without any details are left to the enrichment worker if asked.
+const insertImportSongs = `WITH inserted AS (
+    INSERT INTO songs (group_id, song_name, release_date, text, link, enrichment_status)
+    SELECT g.id, i.song_name, i.release_date, i.text, i.link,
+        CASE WHEN $1::boolean AND i.release_date IS NULL AND i.text IS NULL AND i.link IS NULL
+            THEN 'pending' ELSE 'done' END
+    FROM import_songs i
+    JOIN groups g ON g.group_name = i.group_name
+    WHERE i.skip_reason IS NULL
+    ORDER BY i.row_number
+    RETURNING id, enrichment_status
+), jobs AS (
+    INSERT INTO enrichment_jobs (song_id)
+    SELECT id FROM inserted WHERE enrichment_status = 'pending'
+    RETURNING song_id
+)
+SELECT (SELECT COUNT(*) FROM inserted), (SELECT COUNT(*) FROM jobs)`
+
+const listImportSkipped = `SELECT row_number, skip_reason
+FROM import_songs
+WHERE skip_reason IS NOT NULL
+ORDER BY row_number`
+
+type ImportSkip struct {
+	RowNumber int32
+	Reason    string
+}
+
+type FinishImportRow struct {
+	GroupsCreated int64
+	Inserted      int64
+	Enqueued      int64
+	Skipped       []ImportSkip
+}
+
+// FinishImport creates the missing groups and inserts the staged songs
+// that are neither duplicates within the import nor already stored. With
+// enrich set, songs without details are queued for enrichment.
+func (q *Queries) FinishImport(ctx context.Context, enrich bool) (FinishImportRow, error) {
+	var i FinishImportRow
+
+	result, err := q.db.ExecContext(ctx, upsertImportGroups)
+	if err != nil {
+		return i, err
+	}
+	if i.GroupsCreated, err = result.RowsAffected(); err != nil {
+		return i, err
+	}
+
+	if _, err := q.db.ExecContext(ctx, skipImportDuplicates); err != nil {
+		return i, err
+	}
+	if _, err := q.db.ExecContext(ctx, skipImportExisting); err != nil {
+		return i, err
+	}
+
+	if err := q.db.QueryRowContext(ctx, insertImportSongs, enrich).Scan(&i.Inserted, &i.Enqueued); err != nil {
+		return i, err
+	}
+
+	rows, err := q.db.QueryContext(ctx, listImportSkipped)
+	if err != nil {
+		return i, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var skip ImportSkip
+		if err := rows.Scan(&skip.RowNumber, &skip.Reason); err != nil {
+			return i, err
+		}
+		i.Skipped = append(i.Skipped, skip)
+	}
+	if err := rows.Close(); err != nil {
+		return i, err
+	}
+	if err := rows.Err(); err != nil {
+		return i, err
+	}
+	return i, nil
+}
@@ -0,0 +1,159 @@
+// Package validate checks request structs against rules declared in
+// `validate` struct tags, e.g. `validate:"required,max=255"`.
+//
+// Supported rules:
+//
+//	required   the field is set: non-nil, non-zero, not blank for strings
+//	notblank   a set string is not blank (for optional pointer fields)
+//	notnull    a Field is not an explicit null
+//	min=N      numbers are at least N, strings have at least N characters
+//	max=N      numbers are at most N, strings have at most N characters
+//	date       a date in the DateLayout format
+//	url        an absolute http or https URL
+//	oneof=a b  one of the space separated values
+//
+// Rules other than required, notblank and notnull skip unset pointers and
+// Fields and empty strings, so optional fields only need to be valid when
+// given. Fields are reported under their JSON names.
+package validate
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/par1ram/song-library/internal/apierr"
+)
+
+// DateLayout is the only date format the API accepts and returns.
+const DateLayout = "2006-01-02"
+
+// Field is implemented by wrapper types that tell an absent value from an
+// explicit null, such as the members of a merge patch. Value returns nil
+// for a null, and present reports whether the member was given at all.
+type Field interface {
+	FieldValue() (value any, present bool)
+}
+
+// Struct validates the struct v or v points to and returns an error per
+// invalid field, in field order.
+func Struct(v any) []apierr.FieldError {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+
+	var errs []apierr.FieldError
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		value := rv.Field(i)
+		set, null := true, false
+		if f, ok := value.Interface().(Field); ok {
+			v, present := f.FieldValue()
+			set, null = v != nil, present && v == nil
+			value = reflect.ValueOf(v)
+		} else if value.Kind() == reflect.Pointer {
+			set = !value.IsNil()
+			if set {
+				value = value.Elem()
+			}
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			name, arg, _ := strings.Cut(rule, "=")
+			if msg := check(name, arg, value, set, null); msg != "" {
+				errs = append(errs, apierr.FieldError{Field: jsonName(field), Message: msg})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+func check(rule, arg string, value reflect.Value, set, null bool) string {
+	switch rule {
+	case "required":
+		if !set || value.IsZero() || (value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "") {
+			return "is required"
+		}
+		return ""
+	case "notblank":
+		if set && value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "" {
+			return "must not be blank"
+		}
+		return ""
+	case "notnull":
+		if null {
+			return "must not be null"
+		}
+		return ""
+	}
+
+	if !set || (value.Kind() == reflect.String && value.String() == "") {
+		return ""
+	}
+
+	switch rule {
+	case "min", "max":
+		limit, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: invalid %s argument %q", rule, arg))
+		}
+		return checkBound(rule, limit, value)
+	case "date":
+		if _, err := time.Parse(DateLayout, value.String()); err != nil {
+			return "must be a date in YYYY-MM-DD format"
+		}
+	case "url":
+		link, err := url.ParseRequestURI(value.String())
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+			return "must be an absolute http or https URL"
+		}
+	case "oneof":
+		allowed := strings.Fields(arg)
+		if !slices.Contains(allowed, fmt.Sprint(value.Interface())) {
+			return "must be one of: " + strings.Join(allowed, ", ")
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+	return ""
+}
+
+func checkBound(rule string, limit int64, value reflect.Value) string {
+	var n int64
+	unit := ""
+	switch value.Kind() {
+	case reflect.String:
+		n = int64(utf8.RuneCountInString(value.String()))
+		unit = " characters"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = value.Int()
+	default:
+		panic(fmt.Sprintf("validate: %s does not apply to %s", rule, value.Kind()))
+	}
+
+	if rule == "min" && n < limit {
+		return fmt.Sprintf("must be at least %d%s", limit, unit)
+	}
+	if rule == "max" && n > limit {
+		return fmt.Sprintf("must be at most %d%s", limit, unit)
+	}
+	return ""
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,193 @@
+package validate_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/par1ram/song-library/api"
+	"github.com/par1ram/song-library/internal/apierr"
+	"github.com/par1ram/song-library/internal/validate"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func set[T any](v T) api.PatchField[T] {
+	return api.PatchField[T]{Set: true, Value: v}
+}
+
+func null[T any]() api.PatchField[T] {
+	return api.PatchField[T]{Set: true, Null: true}
+}
+
+type plainRequest struct {
+	Name  string `json:"name" validate:"required,max=5"`
+	Count int32  `json:"count" validate:"min=1,max=10"`
+	Date  string `json:"date" validate:"date"`
+	Link  string `json:"link" validate:"url"`
+	Mode  string `json:"mode" validate:"oneof=fast slow"`
+	Note  string `validate:"min=2"`
+}
+
+type pointerRequest struct {
+	Name  *string `json:"name" validate:"required"`
+	Title *string `json:"title,omitempty" validate:"notblank,max=3"`
+	Count *int32  `json:"count" validate:"min=1"`
+}
+
+type patchRequest struct {
+	GroupID api.PatchField[int32]  `json:"group_id" validate:"notnull,min=1"`
+	Name    api.PatchField[string] `json:"name" validate:"notnull,notblank,max=3"`
+	Date    api.PatchField[string] `json:"date" validate:"date"`
+	Link    api.PatchField[string] `json:"link" validate:"required,url"`
+}
+
+func TestStruct(t *testing.T) {
+	validPlain := plainRequest{Name: "Muse", Count: 1, Date: "2006-07-16", Link: "https://example.com", Mode: "fast", Note: "ok"}
+
+	tests := []struct {
+		name string
+		req  any
+		want []apierr.FieldError
+	}{
+		{
+			name: "valid plain",
+			req:  validPlain,
+		},
+		{
+			name: "pointer to a struct",
+			req:  &validPlain,
+		},
+		{
+			name: "optional plain fields left empty",
+			req:  plainRequest{Name: "Muse", Count: 5},
+		},
+		{
+			name: "required and bounds",
+			req:  plainRequest{Name: "   ", Count: 11, Note: "x"},
+			want: []apierr.FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "count", Message: "must be at most 10"},
+				{Field: "Note", Message: "must be at least 2 characters"},
+			},
+		},
+		{
+			name: "first failing rule only",
+			req:  plainRequest{Name: "Muse", Count: 0},
+			want: []apierr.FieldError{{Field: "count", Message: "must be at least 1"}},
+		},
+		{
+			name: "max counts characters",
+			req:  plainRequest{Name: "Мьюзик", Count: 1},
+			want: []apierr.FieldError{{Field: "name", Message: "must be at most 5 characters"}},
+		},
+		{
+			name: "date, url and oneof",
+			req:  plainRequest{Name: "Muse", Count: 1, Date: "16.07.2006", Link: "ftp://example.com", Mode: "medium"},
+			want: []apierr.FieldError{
+				{Field: "date", Message: "must be a date in YYYY-MM-DD format"},
+				{Field: "link", Message: "must be an absolute http or https URL"},
+				{Field: "mode", Message: "must be one of: fast, slow"},
+			},
+		},
+		{
+			name: "relative url",
+			req:  plainRequest{Name: "Muse", Count: 1, Link: "/songs/1"},
+			want: []apierr.FieldError{{Field: "link", Message: "must be an absolute http or https URL"}},
+		},
+		{
+			name: "valid pointers",
+			req:  pointerRequest{Name: ptr("Muse"), Title: ptr("abc"), Count: ptr[int32](1)},
+		},
+		{
+			name: "nil pointers skip all but required",
+			req:  pointerRequest{},
+			want: []apierr.FieldError{{Field: "name", Message: "is required"}},
+		},
+		{
+			name: "set pointers",
+			req:  pointerRequest{Name: ptr(""), Title: ptr(" "), Count: ptr[int32](0)},
+			want: []apierr.FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "title", Message: "must not be blank"},
+				{Field: "count", Message: "must be at least 1"},
+			},
+		},
+		{
+			name: "pointer max",
+			req:  pointerRequest{Name: ptr("Muse"), Title: ptr("abcd")},
+			want: []apierr.FieldError{{Field: "title", Message: "must be at most 3 characters"}},
+		},
+		{
+			name: "valid patch",
+			req:  patchRequest{GroupID: set[int32](1), Name: set("abc"), Date: set("2006-07-16"), Link: set("http://example.com")},
+		},
+		{
+			name: "absent patch members skip all but required",
+			req:  patchRequest{},
+			want: []apierr.FieldError{{Field: "link", Message: "is required"}},
+		},
+		{
+			name: "null patch members",
+			req:  patchRequest{GroupID: null[int32](), Name: null[string](), Date: null[string](), Link: null[string]()},
+			want: []apierr.FieldError{
+				{Field: "group_id", Message: "must not be null"},
+				{Field: "name", Message: "must not be null"},
+				{Field: "link", Message: "is required"},
+			},
+		},
+		{
+			name: "set patch members",
+			req:  patchRequest{GroupID: set[int32](0), Name: set(" "), Date: set("2006-13-01"), Link: set("example.com")},
+			want: []apierr.FieldError{
+				{Field: "group_id", Message: "must be at least 1"},
+				{Field: "name", Message: "must not be blank"},
+				{Field: "date", Message: "must be a date in YYYY-MM-DD format"},
+				{Field: "link", Message: "must be an absolute http or https URL"},
+			},
+		},
+		{
+			name: "patch max",
+			req:  patchRequest{Name: set("abcd"), Link: set("https://example.com")},
+			want: []apierr.FieldError{{Field: "name", Message: "must be at most 3 characters"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validate.Struct(tt.req)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Struct = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructPanicsOnBadTags(t *testing.T) {
+	tests := []struct {
+		name string
+		req  any
+	}{
+		{"unknown rule", struct {
+			A string `validate:"email"`
+		}{"a"}},
+		{"bad bound", struct {
+			A int `validate:"min=x"`
+		}{1}},
+		{"bound on a bool", struct {
+			A bool `validate:"max=1"`
+		}{true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Struct did not panic")
+				}
+			}()
+			validate.Struct(tt.req)
+		})
+	}
+}
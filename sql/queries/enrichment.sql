@@ -0,0 +1,63 @@
+-- name: InsertPendingSong :one
+INSERT INTO songs (group_id, song_name, enrichment_status)
+VALUES ($1, $2, 'pending')
+RETURNING id;
+
+-- name: EnqueueEnrichmentJob :exec
+INSERT INTO enrichment_jobs (song_id) VALUES ($1)
+ON CONFLICT (song_id) DO UPDATE
+SET status = 'pending', attempts = 0, last_error = NULL,
+    run_after = now(), leased_until = NULL, updated_at = now();
+
+-- name: LeaseEnrichmentJob :one
+UPDATE enrichment_jobs j
+SET leased_until = now() + make_interval(secs => @lease_seconds::float8),
+    attempts = j.attempts + 1,
+    updated_at = now()
+FROM songs s
+JOIN groups g ON s.group_id = g.id
+WHERE j.id = (
+    SELECT id FROM enrichment_jobs
+    WHERE status = 'pending'
+      AND run_after <= now()
+      AND (leased_until IS NULL OR leased_until < now())
+    ORDER BY run_after, id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+  )
+  AND s.id = j.song_id
+RETURNING j.id, j.song_id, j.attempts, s.song_name, g.group_name;
+
+-- name: CompleteSongEnrichment :execrows
+-- Only a pending song is enriched, and only its missing details are
+-- filled in, so edits made while the job was queued are kept.
+UPDATE songs
+SET
+    release_date = COALESCE(release_date, sqlc.narg('release_date')::date),
+    text = COALESCE(text, sqlc.narg('text')::text),
+    link = COALESCE(link, sqlc.narg('link')::text),
+    enrichment_status = 'done'
+WHERE id = sqlc.arg('id') AND enrichment_status = 'pending';
+
+-- name: UpdateSongEnrichment :execrows
+-- Overwrites the details of a song, but only at the version they were
+-- compared against, so a concurrent edit is never lost.
+UPDATE songs
+SET release_date = $2, text = $3, link = $4, enrichment_status = 'done'
+WHERE id = $1 AND version = $5;
+
+-- name: SetSongEnrichmentStatus :exec
+UPDATE songs SET enrichment_status = $2 WHERE id = $1;
+
+-- name: DeleteEnrichmentJob :exec
+DELETE FROM enrichment_jobs WHERE id = $1;
+
+-- name: RescheduleEnrichmentJob :exec
+UPDATE enrichment_jobs
+SET last_error = $2, run_after = $3, leased_until = NULL, updated_at = now()
+WHERE id = $1;
+
+-- name: FailEnrichmentJob :exec
+UPDATE enrichment_jobs
+SET status = 'failed', last_error = $2, leased_until = NULL, updated_at = now()
+WHERE id = $1;
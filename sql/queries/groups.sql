@@ -1,2 +1,25 @@
 -- name: GetGroupIDByGroupName :one
 SELECT id FROM groups WHERE group_name = $1;
+
+-- name: InsertGroup :one
+INSERT INTO groups (group_name) VALUES ($1) RETURNING *;
+
+-- name: GetGroupByID :one
+SELECT * FROM groups WHERE id = $1;
+
+-- name: ListGroups :many
+SELECT * FROM groups ORDER BY group_name LIMIT $1 OFFSET $2;
+
+-- name: UpdateGroupName :one
+UPDATE groups SET group_name = $2 WHERE id = $1 RETURNING *;
+
+-- name: DeleteGroup :execrows
+DELETE FROM groups WHERE id = $1;
+
+-- name: CountSongsByGroupID :one
+SELECT COUNT(*) FROM songs WHERE group_id = $1;
+
+-- name: UpsertGroup :one
+INSERT INTO groups (group_name) VALUES ($1)
+ON CONFLICT (group_name) DO UPDATE SET group_name = EXCLUDED.group_name
+RETURNING id, (xmax = 0) AS inserted;
@@ -0,0 +1,13 @@
+-- name: GetSongInfoCacheEntry :one
+SELECT * FROM song_info_cache
+WHERE cache_key = $1 AND expires_at > now();
+
+-- name: UpsertSongInfoCacheEntry :exec
+INSERT INTO song_info_cache (cache_key, found, release_date, text, link, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (cache_key) DO UPDATE
+SET found = EXCLUDED.found, release_date = EXCLUDED.release_date, text = EXCLUDED.text,
+    link = EXCLUDED.link, expires_at = EXCLUDED.expires_at;
+
+-- name: DeleteExpiredSongInfoCacheEntries :execrows
+DELETE FROM song_info_cache WHERE expires_at <= now();
@@ -3,21 +3,39 @@ INSERT INTO songs (group_id, song_name, release_date, text, link)
 VALUES ($1, $2, $3, $4, $5)
 RETURNING id;
 
--- name: UpdateSong :exec
-UPDATE songs SET group_id = $2, song_name = $3, text = $4, release_date = $5, link = $6 WHERE id = $1;
+-- name: UpdateSong :one
+-- versions, unless NULL, lists the versions the song must have (If-Match).
+UPDATE songs
+SET group_id = sqlc.arg('group_id'), song_name = sqlc.arg('song_name'), text = sqlc.arg('text'), release_date = sqlc.arg('release_date'), link = sqlc.arg('link')
+WHERE id = sqlc.arg('id')
+  AND (sqlc.narg('versions')::int[] IS NULL OR version = ANY(sqlc.narg('versions')::int[]))
+RETURNING version;
+
+-- name: DeleteSong :execrows
+DELETE FROM songs
+WHERE id = sqlc.arg('id')
+  AND (sqlc.narg('versions')::int[] IS NULL OR version = ANY(sqlc.narg('versions')::int[]));
 
--- name: DeleteSong :exec
-DELETE FROM songs WHERE id = $1;
+-- name: LockSong :one
+SELECT version FROM songs WHERE id = $1 FOR UPDATE;
 
--- name: UpdateSongPartial :exec
+-- name: GetSongVersion :one
+SELECT version FROM songs WHERE id = $1;
+
+-- name: UpdateSongPartial :one
+-- The set_ flags tell a NULL that clears a nullable column from an
+-- omitted one, which keeps the current value. versions works as in
+-- UpdateSong.
 UPDATE songs
 SET
-    group_id = COALESCE($2, group_id),
-    song_name = COALESCE($3, song_name),
-    text = COALESCE($4, text),
-    release_date = COALESCE($5, release_date),
-    link = COALESCE($6, link)
-WHERE id = $1;
+    group_id = COALESCE(sqlc.narg('group_id'), group_id),
+    song_name = COALESCE(sqlc.narg('song_name'), song_name),
+    text = CASE WHEN sqlc.arg('set_text')::boolean THEN sqlc.narg('text')::text ELSE text END,
+    release_date = CASE WHEN sqlc.arg('set_release_date')::boolean THEN sqlc.narg('release_date')::date ELSE release_date END,
+    link = CASE WHEN sqlc.arg('set_link')::boolean THEN sqlc.narg('link')::text ELSE link END
+WHERE id = sqlc.arg('id')
+  AND (sqlc.narg('versions')::int[] IS NULL OR version = ANY(sqlc.narg('versions')::int[]))
+RETURNING version;
 
 -- name: GetSongsFiltered :many
 SELECT *
@@ -29,3 +47,6 @@ WHERE ($1 IS NULL OR group_id = $1)
   AND ($5 IS NULL OR link ILIKE '%' || $5 || '%')
 ORDER BY id
 LIMIT $6 OFFSET $7;
+
+-- name: DeleteSongsByGroupID :execrows
+DELETE FROM songs WHERE group_id = $1;
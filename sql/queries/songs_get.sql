@@ -1,20 +1,32 @@
--- name: GetSongWithFiltersAndPagination :many
-SELECT s.id, g.group_name, s.song_name, s.release_date, s.link
+-- name: GetSongVersesWithPagination :many
+SELECT v.verse::text AS verse
+FROM songs s,
+  unnest(string_to_array(s.text, E'\n\n')) WITH ORDINALITY AS v(verse, n)
+WHERE s.id = $1 AND btrim(v.verse) <> ''
+ORDER BY v.n
+LIMIT $2 OFFSET $3;
+
+-- name: CountSongVerses :one
+SELECT (
+  SELECT COUNT(*)
+  FROM unnest(string_to_array(s.text, E'\n\n')) AS v(verse)
+  WHERE btrim(v.verse) <> ''
+) AS total
+FROM songs s
+WHERE s.id = $1;
+
+-- name: ListSongsForResync :many
+SELECT s.id, s.song_name, g.group_name, s.release_date, s.text, s.link, s.version
 FROM songs s
 JOIN groups g ON s.group_id = g.id
-WHERE 
-  (g.group_name ILIKE '%' || $1 || '%' OR $1 IS NULL) AND
-  (s.song_name ILIKE '%' || $2 || '%' OR $2 IS NULL) AND
-  (s.release_date = $3 OR $3 IS NULL)
-ORDER BY s.release_date DESC
-LIMIT $4 OFFSET $5;
+WHERE (sqlc.narg('song_id')::int IS NULL OR s.id = sqlc.narg('song_id'))
+  AND (sqlc.narg('group_id')::int IS NULL OR s.group_id = sqlc.narg('group_id'))
+  AND s.id > @after_id
+ORDER BY s.id
+LIMIT @batch_size;
 
--- name: GetSongVersesWithPagination :one
-WITH verses AS (
-  SELECT unnest(string_to_array(text, E'\n\n')) AS verse
-  FROM songs
-  WHERE id = $1
-)
-SELECT verse
-FROM verses
-LIMIT $2 OFFSET $3;
+-- name: GetSongByID :one
+SELECT s.id, s.song_name, s.release_date, s.text, s.link, s.group_id, s.enrichment_status, g.group_name, s.created_at, s.updated_at, s.version
+FROM songs s
+JOIN groups g ON s.group_id = g.id
+WHERE s.id = $1;
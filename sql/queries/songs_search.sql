@@ -0,0 +1,11 @@
+-- name: SearchSongs :many
+SELECT s.id, g.group_name, s.song_name, s.release_date, s.link,
+  ts_rank_cd(s.search_vector, q.query)::real AS rank,
+  ts_headline(@config::regconfig, coalesce(s.text, s.song_name), q.query,
+    'MaxFragments=2, MinWords=5, MaxWords=20')::text AS snippet
+FROM songs s
+JOIN groups g ON s.group_id = g.id
+CROSS JOIN websearch_to_tsquery(@config::regconfig, @query::text) AS q(query)
+WHERE s.search_vector @@ q.query
+ORDER BY rank DESC, s.id
+LIMIT @page_limit OFFSET @page_offset;
@@ -0,0 +1,14 @@
+-- name: SuggestGroups :many
+SELECT id, group_name, similarity(group_name, $1)::real AS score
+FROM groups
+WHERE group_name % $1
+ORDER BY score DESC, group_name
+LIMIT $2;
+
+-- name: SuggestSongs :many
+SELECT s.id, s.song_name, g.group_name, similarity(s.song_name, $1)::real AS score
+FROM songs s
+JOIN groups g ON s.group_id = g.id
+WHERE s.song_name % $1
+ORDER BY score DESC, s.song_name
+LIMIT $2;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE songs ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', coalesce(song_name, '')), 'A') ||
+  setweight(to_tsvector('english', coalesce(song_name, '')), 'A') ||
+  setweight(to_tsvector('russian', coalesce(song_name, '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(text, '')), 'B') ||
+  setweight(to_tsvector('english', coalesce(text, '')), 'B') ||
+  setweight(to_tsvector('russian', coalesce(text, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_songs_search_vector ON songs USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_songs_search_vector;
+ALTER TABLE songs DROP COLUMN IF EXISTS search_vector;
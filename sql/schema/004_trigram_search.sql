@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_groups_group_name_trgm ON groups USING GIN (group_name gin_trgm_ops);
+CREATE INDEX idx_songs_song_name_trgm ON songs USING GIN (song_name gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_songs_song_name_trgm;
+DROP INDEX IF EXISTS idx_groups_group_name_trgm;
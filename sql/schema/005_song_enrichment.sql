@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE songs ADD COLUMN enrichment_status TEXT NOT NULL DEFAULT 'done'
+  CHECK (enrichment_status IN ('pending', 'done', 'failed'));
+
+CREATE TABLE enrichment_jobs (
+  id SERIAL PRIMARY KEY,
+  song_id INTEGER NOT NULL UNIQUE,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'failed')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  run_after TIMESTAMPTZ NOT NULL DEFAULT now(),
+  leased_until TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  FOREIGN KEY (song_id) REFERENCES songs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_enrichment_jobs_pending ON enrichment_jobs (run_after) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE IF EXISTS enrichment_jobs;
+ALTER TABLE songs DROP COLUMN IF EXISTS enrichment_status;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE song_info_cache (
+  cache_key TEXT PRIMARY KEY,
+  found BOOLEAN NOT NULL,
+  release_date TEXT NOT NULL DEFAULT '',
+  text TEXT NOT NULL DEFAULT '',
+  link TEXT NOT NULL DEFAULT '',
+  expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_song_info_cache_expires_at ON song_info_cache (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS song_info_cache;
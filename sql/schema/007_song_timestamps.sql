@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE songs
+  ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- +goose StatementBegin
+CREATE FUNCTION songs_set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER songs_set_updated_at
+  BEFORE UPDATE ON songs
+  FOR EACH ROW EXECUTE FUNCTION songs_set_updated_at();
+
+CREATE INDEX idx_songs_created_at ON songs (created_at);
+CREATE INDEX idx_songs_updated_at ON songs (updated_at);
+
+-- +goose Down
+DROP TRIGGER IF EXISTS songs_set_updated_at ON songs;
+DROP FUNCTION IF EXISTS songs_set_updated_at();
+ALTER TABLE songs DROP COLUMN IF EXISTS created_at, DROP COLUMN IF EXISTS updated_at;
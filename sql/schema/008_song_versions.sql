@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE songs ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- Every update of a song bumps its version, which is served as the ETag.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION songs_set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.updated_at = now();
+  NEW.version = OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION songs_set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+ALTER TABLE songs DROP COLUMN IF EXISTS version;
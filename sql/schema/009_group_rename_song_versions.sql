@@ -0,0 +1,22 @@
+-- +goose Up
+-- Songs are served with their group name, so renaming a group changes
+-- every song of it. Touching them bumps their versions and with that their
+-- ETags, which keeps cached copies and If-Match writes honest.
+-- +goose StatementBegin
+CREATE FUNCTION groups_touch_songs() RETURNS TRIGGER AS $$
+BEGIN
+  UPDATE songs SET version = version WHERE group_id = NEW.id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER groups_touch_songs
+  AFTER UPDATE OF group_name ON groups
+  FOR EACH ROW
+  WHEN (OLD.group_name IS DISTINCT FROM NEW.group_name)
+  EXECUTE FUNCTION groups_touch_songs();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS groups_touch_songs ON groups;
+DROP FUNCTION IF EXISTS groups_touch_songs();